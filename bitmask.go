@@ -142,6 +142,17 @@ func (b Bitmask) up(color int) Bitmask {
 	return b >> 8
 }
 
+// Returns the bitmask with all its bits smeared towards the promotion rank of
+// the given side, ex. pawns along with all the squares in front of them.
+func (b Bitmask) forward(color int) Bitmask {
+	if color == White {
+		b |= b << 8; b |= b << 16; b |= b << 32
+	} else {
+		b |= b >> 8; b |= b >> 16; b |= b >> 32
+	}
+	return b
+}
+
 // Returns bitmask with least significant bit off.
 func (b Bitmask) pop() Bitmask {
 	return b & (b - 1)
@@ -10,10 +10,8 @@ package donna
 
 import (
 	`encoding/binary`
-	`math/rand`
 	`os`
 	`sort`
-	`time`
 )
 
 // Many pages make a thick book.
@@ -43,12 +41,6 @@ func NewBook(bookFile string) (*Book, error) {
 }
 
 func (b *Book) pickMove(position *Position) Move {
-	// The generation of random numbers is too important to be left to chance.
-	// Returns pseudo-random integer in [0, limit] range. It panics if limit <= 0.
-	random := func(limit int) int {
-		rand.Seed(time.Now().Unix()); return rand.Intn(limit)
-	}
-
 	entries := b.lookup(position)
 	switch length := len(entries); length {
 	case 0:
@@ -58,11 +50,36 @@ func (b *Book) pickMove(position *Position) Move {
 		// The only move available.
 		return b.move(position, entries[0])
 	default:
-		// Sort book entries by score and pick among two best moves.
+		// Sort book entries by weight and either play the best move or
+		// pick random one with probability proportional to its weight.
 		sort.Sort(byBookScore{entries})
-		best := min(2, len(entries))
-		return b.move(position, entries[random(best)])
+		if engine.bookBest {
+			return b.move(position, entries[0])
+		}
+		return b.move(position, entries[b.weighted(entries)])
+	}
+}
+
+// Returns index of random book entry picked with probability proportional to
+// its weight. Entries with zero weight are never picked unless all of them
+// have zero weight.
+func (b *Book) weighted(entries []Entry) int {
+	total := 0
+	for _, entry := range entries {
+		total += int(entry.Score)
 	}
+	if total == 0 {
+		return random.Intn(len(entries))
+	}
+
+	n := random.Intn(total)
+	for i, entry := range entries {
+		if n -= int(entry.Score); n < 0 {
+			return i
+		}
+	}
+
+	return 0
 }
 
 func (b *Book) lookup(position *Position) (entries []Entry) {
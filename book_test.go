@@ -8,7 +8,15 @@
 
 package donna
 
-import(`github.com/michaeldv/donna/expect`; `testing`)
+import (
+	`github.com/michaeldv/donna/expect`
+	`bytes`
+	`encoding/binary`
+	`io/ioutil`
+	`os`
+	`strings`
+	`testing`
+)
 
 func openBook() (*Book, *Position) {
 	return &Book{}, NewGame().start()
@@ -164,3 +172,79 @@ func TestBook110(t *testing.T) { // 1. d4 d5
 	expect.Eq(t, p.enpassant, 0)
 	expect.Eq(t, p.castles, uint8(0x0F))
 }
+
+// Picks a sequence of book moves from the initial position given the seed.
+func pickBookMoves(book *Book, seed uint64) (moves []string) {
+	SetSeed(seed); defer SetSeed(defaultSeed)
+	for i := 0; i < 32; i++ {
+		moves = append(moves, book.pickMove(NewGame().start()).str())
+	}
+	return moves
+}
+
+// Creates tiny book with weighted moves from the initial position.
+func tinyBook(weights ...uint16) string {
+	file, _ := ioutil.TempFile(``, `donna`)
+	defer file.Close()
+
+	key := NewGame().start().id
+	binary.Write(file, binary.BigEndian, Entry{ Key: 0 })
+	for i, entry := range []Entry{ polyglotEntry(E2, E4), polyglotEntry(D2, D4), polyglotEntry(G1, F3) } {
+		entry.Key, entry.Score = key, weights[i]
+		binary.Write(file, binary.BigEndian, entry)
+	}
+	binary.Write(file, binary.BigEndian, Entry{ Key: key + 1 })
+
+	return file.Name()
+}
+
+func TestBook200(t *testing.T) { // Same seed picks same moves.
+	fileName := tinyBook(10, 10, 10)
+	defer os.Remove(fileName)
+
+	book, _ := NewBook(fileName)
+	expect.Eq(t, pickBookMoves(book, 42), pickBookMoves(book, 42))
+	expect.Ne(t, pickBookMoves(book, 42), pickBookMoves(book, 24))
+}
+
+func TestBook210(t *testing.T) { // Best weight vs. random weighted selection.
+	fileName := tinyBook(30, 10, 0)
+	defer os.Remove(fileName)
+	defer func() { engine.bookBest = false }()
+	book, _ := NewBook(fileName)
+
+	picks := map[string]int{}
+	for _, move := range pickBookMoves(book, 42) {
+		picks[move]++
+	}
+	expect.Eq(t, picks[`e2-e4`] + picks[`d2-d4`], 32)
+	expect.True(t, picks[`e2-e4`] > picks[`d2-d4`] && picks[`d2-d4`] > 0)
+
+	engine.bookBest = true
+	for _, move := range pickBookMoves(book, 42) {
+		expect.Eq(t, move, `e2-e4`)
+	}
+}
+
+func TestBook220(t *testing.T) { // UCI book options: book move bypasses the search.
+	fileName := tinyBook(0, 10, 0)
+	defer os.Remove(fileName)
+	defer func() { engine = Engine{} }()
+
+	var output bytes.Buffer
+	NewEngine(`input`, strings.NewReader("setoption name BookFile value " + fileName + "\nposition startpos\ngo depth 3\n"), `output`, &output)
+	engine.Uci()
+	expect.Contain(t, output.String(), "info nodes 0 time ")
+	expect.Contain(t, output.String(), "\nbestmove d2d4\n")
+
+	output.Reset()
+	NewEngine(`input`, strings.NewReader("setoption name BookFile value " + fileName + "\nsetoption name OwnBook value false\nposition startpos\ngo depth 3\n"), `output`, &output)
+	engine.Uci()
+	expect.Contain(t, output.String(), "info depth 3 ")
+
+	output.Reset()
+	NewEngine(`input`, strings.NewReader("setoption name BookFile value missing.bin\n"), `output`, &output)
+	engine.Uci()
+	expect.Eq(t, engine.bookFile, ``)
+	expect.Contain(t, output.String(), "info string ")
+}
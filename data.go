@@ -45,6 +45,12 @@ const (
 	BlackWinning = -Checkmate / 10 + 1 // Decisive advantage for Black.
 )
 
+// Game phase thresholds: above phaseOpening is opening, below phaseEndgame is endgame.
+const (
+	phaseOpening = 224	// Up to a couple of minor pieces traded off.
+	phaseEndgame = 96	// About a queen or a rook and two minors per side left.
+)
+
 // Square indices.
 const (
 	A1 = iota
@@ -102,6 +108,11 @@ var maskIsolated = [8]Bitmask{
 
 var up = [2]int{ 8, -8 }
 var homeKing = [2]int{ E1, E8 }
+
+// Castle rook squares for kingside and queenside castles.
+var castleRook = [2][2]int{
+	{ H1, A1 }, { H8, A8 },
+}
 var mask7th = [2]Bitmask{ maskRank[6], maskRank[1] }
 var mask8th = [2]Bitmask{ maskRank[7], maskRank[0] }
 
@@ -20,20 +20,44 @@ var (
 	rightToMove    = Score{ 10, 10 }  // Tempo bonus.
 	bishopPawn     = Score{  4,  6 }  // Penalty for each pawn on the same colored square as a bishop.
 	bishopBoxed    = Score{ 73,  0 }  // Penalty for patterns like Bc1,d2,Nd3.
+	badBishop      = Score{  6, 10 }  // Penalty for each pawn fixed by enemy pawn on the same colored square as a bishop.
+	bonusBishopPair = Score{ 12, 24 }  // Bonus for having both bishops on top of material imbalance.
 	rookOnPawn     = Score{  6, 14 }  // Bonus for rook attacking a pawn.
 	rookOnOpen     = Score{ 22, 10 }  // Bonus for rook on open file.
 	rookOnSemiOpen = Score{ 10,  5 }  // Bonus for rook on semi-open file.
 	rookOn7th      = Score{  5, 10 }  // Bonus for rook on 7th file.
+	rooksOn7th     = Score{ 25, 40 }  // Extra bonus for two rooks on 7th file.
 	rookBoxed      = Score{ 45,  0 }  // Penalty for rook boxed by king.
+	rookLift       = Score{ 10,  0 }  // Bonus for rook that can be lifted toward enemy's king.
+	rookBehindPasser = Score{ 0, 6 }  // Bonus for rook behind friendly passer, per passer's rank.
+	rookBehindEnemy  = Score{ 0, 4 }  // Penalty for enemy's rook behind our passer, per passer's rank.
 	behindPawn     = Score{  8,  0 }  // Bonus for knight and bishop being behind friendly pawn.
+	knightReach    = Score{  8,  4 }  // Bonus for knight that can reach an outpost in two moves.
+	knightBad      = Score{ 14,  8 }  // Penalty for knight hemmed by enemy pawns with no outpost in reach.
+	knightOnRim    = Score{  8,  2 }  // Penalty for knight on A or H file.
+	knightInCorner = Score{ 20,  6 }  // Penalty for knight in the corner.
+	restrictMove   = Score{  2,  0 }  // Bonus for each square denied to enemy pieces.
+	queenTrapped   = Score{ 60, 40 }  // Penalty for each missing escape square of trapped queen.
 	hangingAttack  = Score{ 24, 14 }  // Bonus for attacking enemy pieces that are hanging.
 	kingAttack     = Score{  2, 30 }  // Bonus for king attacking other pieces.
 	kingByPawn     = Score{  0,  8 }  // Penalty king being too far from friendly pawns.
 	pawnAlone      = Score{ 10,  5 }  // Penalty for unsupported pawn.
+	pawnLever      = Score{  5,  0 }  // Bonus for pawn that can capture enemy pawn.
+	minorityAttack = Score{  8,  2 }  // Bonus for minority attack against enemy queenside majority.
+	minorityWeak   = Score{  6,  6 }  // Penalty for queenside majority facing minority attack.
+	twoWeaknesses  = Score{  4, 24 }  // Penalty for weak pawns on both wings.
+	rookVsMinors   = Score{-32, 12 }  // Rook vs. two minors imbalance adjustment for the rook side.
+	bishopSpread   = Score{  0,  8 }  // Bishop vs. knight bonus for pawns spread on both wings.
+	rookCutOff     = Score{  0, 40 }  // Rook and pawn vs. rook bonus for cutting off enemy king.
+	rookLucena     = Score{  0,150 }  // Rook and pawn vs. rook bonus for Lucena position.
+	opposition     = Score{  0, 48 }  // Pawn endgame bonus for holding the opposition.
+	exchangeSac    = Score{ 24, 16 }  // Compensation for the exchange, per compensating factor.
+	weakHelpless   = Score{ 12, 20 }  // Penalty for weak pawn that our pieces can't defend.
 )
 
 // Weight percentages applied to evaluation scores before computing the overall
-// blended score.
+// blended score. Midgame and endgame weights are applied separately, so each
+// weight effectively tapers with the game phase.
 var (
 	weightMobility      = Score{ 108, 134 }
 	weightPawnStructure = Score{  91,  79 }
@@ -205,10 +229,31 @@ var bonusPassedPawn = [8]Score{
 	{0, 0}, {0, 3}, {0, 7}, {17, 17}, {51, 35}, {102, 59}, {170, 91}, {0, 0},
 }
 
+// Endgame bonus for the number of tempos the passer needs to promote.
+var bonusTempoPassedPawn = [8]int{
+	0, 40, 28, 18, 10, 5, 2, 0,
+}
+
+// Bonus for passed pawn connected to another passer on adjacent file.
+var bonusConnectedPassers = [8]Score{
+	{0, 0}, {0, 0}, {2, 4}, {4, 8}, {8, 16}, {20, 40}, {40, 80}, {0, 0},
+}
+
+// Bonus for passed pawn supported by another passer on adjacent file.
+var bonusPassedPawnDuo = [8]Score{
+	{0, 0}, {0, 0}, {4, 10}, {10, 25}, {24, 60}, {45, 115}, {75, 180}, {0, 0},
+}
+
 var bonusSemiPassedPawn = [8]Score{
 	{0, 0}, {3, 6}, {3, 6}, {7, 14}, {17, 34}, {41, 83}, {0, 0}, {0, 0},
 }
 
+// Bonus for candidate passer, i.e. the pawn that becomes passed by capturing
+// enemy pawn.
+var bonusCandidatePasser = [8]Score{
+	{0, 0}, {5, 10}, {5, 10}, {10, 20}, {24, 48}, {55, 110}, {0, 0}, {0, 0},
+}
+
 var extraPassedPawn = [8]int{
 	0, 0, 0, 1, 3, 6, 10, 0,
 }
@@ -292,14 +337,38 @@ var penaltyStormUnblocked = [8]int {
 	13, 16, 48, 19, 10, 0, 0, 0,
 }
 
+// Bonus for our pawn advanced next to enemy king, indexed by rank and then
+// multiplied by proximity to the king.
+var bonusKingPawn = [8]int {
+	0, 0, 0, 0, 6, 12, 16, 0,
+}
+
+// Bonus for knight on an outpost in enemy's half of the board, indexed by rank.
+var bonusKnightOutpost = [8]Score {
+	{0, 0}, {0, 0}, {0, 0}, {0, 0}, {12, 4}, {18, 6}, {0, 0}, {0, 0},
+}
+
+// Bonus for bishop on an outpost in enemy's half of the board, indexed by rank.
+var bonusBishopOutpost = [8]Score {
+	{0, 0}, {0, 0}, {0, 0}, {0, 0}, {6, 2}, {10, 4}, {0, 0}, {0, 0},
+}
+
+// Bonus for each square of proximity to enemy's king indexed by piece kind.
+var kingTropism = [6]int {
+	0, 0, 2, 1, 1, 3,
+}
+
 // [1] Pawn, [2] Knight, [3] Bishop, [4] Rook, [5] Queen
 var penaltyPawnThreat = [6]Score {
 	{0, 0}, {0, 0}, {26, 35}, {26, 35}, {38, 49}, {43, 59},
 }
 
-// Penalty for doubled pawn: A to H, midgame/endgame.
+// Penalty for doubled pawn: A to H, midgame/endgame. Doubled center pawns
+// restrict development and control fewer squares and thus get penalized the
+// most. Doubled rook pawns are mostly harmless and usually come with a half
+// open file next to them.
 var penaltyDoubledPawn = [8]Score{
-	{7, 21}, {10, 24}, {12, 24}, {12, 24}, {12, 24}, {12, 24}, {10, 24}, {7, 21},
+	{3, 14}, {8, 22}, {14, 26}, {18, 28}, {18, 28}, {14, 26}, {8, 22}, {3, 14},
 }
 
 // Penalty for isolated pawn that is *not* exposed: A to H, midgame/endgame.
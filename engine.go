@@ -8,12 +8,29 @@
 
 package donna
 
-import (`fmt`; `os`; `time`)
+import (`fmt`; `io`; `os`; `sync`; `sync/atomic`; `time`)
 
 const Ping = 250 // Check time 4 times a second.
+const defaultQDepth = 16 // Quiescence search plies unless set otherwise.
+
+// Rating range for limited strength play.
+const (
+	minElo = 1000
+	maxElo = 2800
+)
+
+// Tie-break policies for picking among root moves with near-equal scores.
+const (
+	tieBreakNone = iota
+	tieBreakTactical   // Prefer captures, promotions, and checks.
+	tieBreakPositional // Prefer quiet moves that keep the position calm.
+)
+const tieBreakMargin = onePawn / 4 // Root moves within the margin are viewed as equal.
 
 type Clock struct {
-	halt        bool     // Stop search immediately when set to true.
+	halt        atomic.Bool // Stop search immediately when set to true.
+	hit         atomic.Bool // Set by "ponderhit" command until the search picks it up.
+	release     chan struct{} // Closed by "ponderhit" or "stop" to report the move held back while pondering.
 	softStop    int64    // Target soft time limit to make a move.
 	hardStop    int64    // Immediate stop time limit.
 	extra       float32  // Extra time factor based on search volatility.
@@ -21,11 +38,22 @@ type Clock struct {
 	ticker      *time.Ticker
 }
 
+// Best move, its score, and completed search depth published by the root
+// search so that they could be polled while the search is going on.
+type Progress struct {
+	sync.Mutex
+	move        Move
+	score       int
+	depth       int
+}
+
 type Options struct {
 	ponder      bool     // (-) Pondering mode.
 	infinite    bool     // (-) Search until the "stop" command.
+	clearKillers bool    // Clear killer moves before each iteration (reproducible but slightly weaker search).
 	maxDepth    int      // Search X plies only.
 	maxNodes    int      // (-) Search X nodes only.
+	mateIn      int      // (-) Search for mate in X moves only.
 	moveTime    int64    // Search exactly X milliseconds per move.
 	movesToGo   int64    // Number of moves to make till time control.
 	timeLeft    int64    // Time left for all remaining moves.
@@ -35,21 +63,56 @@ type Options struct {
 type Engine struct {
 	log         bool     // Enable logging.
 	uci	    bool     // Use UCI protocol.
+	xboard      bool     // Use XBoard protocol.
+	post        bool     // Show thinking output when using XBoard protocol.
 	trace       bool     // Trace evaluation scores.
 	fancy       bool     // Represent pieces as UTF-8 characters.
+	refutations bool     // Collect refutation lines for all root moves.
+	fixedMaterial bool   // Treat moves that change material signature as leaf nodes.
+	exactMode   bool     // Disable pruning and reductions for exact analysis.
+	noReductions bool    // Disable late move reductions (for A/B testing).
+	noNullMove  bool     // Disable null move pruning (for A/B testing).
+	shuffle     int64    // Seed to shuffle move order in tests (0 to disable).
+	multiPV     int      // Number of principal variations to report (1 if not set).
+	limitStrength bool   // Play at reduced strength approximating elo rating.
+	chess960    bool     // Chess960 (Fischer Random) castle rules for current position.
+	uciChess960 bool     // Chess960 castle rules requested explicitly by UCI_Chess960.
+	elo         int      // Target rating when limiting strength (maxElo if not set).
+	tieBreak    int      // Tie-break policy for near-equal root moves (tieBreakNone if not set).
+	contempt    int      // Draw score offset in centipawns (positive avoids draws).
+	ponder      bool     // Report expected reply to ponder on along with the best move.
 	status      uint8    // Engine status.
 	logFile     string   // Log file name.
 	bookFile    string   // Polyglot opening book file name.
+	bookBest    bool     // Always play the book move with the highest weight.
+	noBook      bool     // Don't consult the opening book even if the book file is set.
+	tablebase   Tablebase // Endgame tablebase to probe (nil disables the built-in KPK bitbase).
 	cacheSize   float64  // Default cache size.
+	keepCache   bool     // Preserve cache entries when resizing the cache.
+	pawnCacheSize int    // Pawn cache size in megabytes (defaultPawnCache if not set).
+	aspiration  int      // Initial aspiration window delta (1/3 of a pawn if not set).
+	widening    int      // Aspiration window growth factor on re-search (2 if not set).
+	maxQDepth   int      // Quiescence search depth limit (defaultQDepth if not set).
+	noQChecks   bool     // Don't generate checks in quiescence search.
+	noQPromos   bool     // Don't search non-capturing queen promotions in quiescence search.
+	activity    int      // Piece activity weight percentage (100 if not set).
+	resign      int      // Resign if the score stays below -resign (0 to disable).
+	resignMoves int      // Number of consecutive moves below resign threshold.
+	onResign    func()   // Optional callback invoked upon resignation.
+	input       io.Reader // Engine command source (standard input if not set).
+	output      io.Writer // Engine output sink (standard output if not set).
+	logger      io.Writer // Debug output sink (log file if not set).
 	clock       Clock
 	options     Options
+	thinking    sync.WaitGroup // Background search started by "go ponder" or "go infinite".
+	progress    Progress // Best move found so far by the ongoing search.
 }
 
 // Use single statically allocated variable.
 var engine Engine
 
 func NewEngine(args ...interface{}) *Engine {
-	engine = Engine{}
+	engine = Engine{tablebase: Bitbase{}}
 	for i := 0; i < len(args); i += 2 {
 		switch value := args[i+1]; args[i] {
 		case `log`:
@@ -58,16 +121,78 @@ func NewEngine(args ...interface{}) *Engine {
 			engine.logFile = value.(string)
 		case `bookfile`:
 			engine.bookFile = value.(string)
+		case `bookbest`:
+			engine.bookBest = value.(bool)
+		case `nobook`:
+			engine.noBook = value.(bool)
+		case `tablebase`:
+			engine.tablebase, _ = value.(Tablebase)
 		case `uci`:
 			engine.uci = value.(bool)
+		case `xboard`:
+			engine.xboard = value.(bool)
 		case `trace`:
 			engine.trace = value.(bool)
 		case `fancy`:
 			engine.fancy = value.(bool)
+		case `refutations`:
+			engine.refutations = value.(bool)
+		case `fixedmaterial`:
+			engine.fixedMaterial = value.(bool)
+		case `exactmode`:
+			engine.exactMode = value.(bool)
+		case `noreductions`:
+			engine.noReductions = value.(bool)
+		case `nonullmove`:
+			engine.noNullMove = value.(bool)
 		case `depth`:
 			engine.options.maxDepth = value.(int)
 		case `movetime`:
 			engine.options.moveTime = int64(value.(int))
+		case `clearkillers`:
+			engine.options.clearKillers = value.(bool)
+		case `aspiration`:
+			engine.aspiration = value.(int)
+		case `widening`:
+			engine.widening = value.(int)
+		case `qdepth`:
+			engine.maxQDepth = value.(int)
+		case `noqchecks`:
+			engine.noQChecks = value.(bool)
+		case `noqpromos`:
+			engine.noQPromos = value.(bool)
+		case `activity`:
+			engine.activity = value.(int)
+		case `multipv`:
+			engine.multiPV = value.(int)
+		case `chess960`:
+			engine.chess960, engine.uciChess960 = value.(bool), value.(bool)
+		case `limitstrength`:
+			engine.limitStrength = value.(bool)
+		case `elo`:
+			engine.elo = value.(int)
+		case `tiebreak`:
+			engine.tieBreak = value.(int)
+		case `contempt`:
+			engine.contempt = value.(int)
+		case `ponder`:
+			engine.ponder = value.(bool)
+		case `resign`:
+			engine.resign = value.(int)
+		case `resignmoves`:
+			engine.resignMoves = value.(int)
+		case `onresign`:
+			engine.onResign = value.(func())
+		case `input`:
+			engine.input = value.(io.Reader)
+		case `output`:
+			engine.output = value.(io.Writer)
+		case `logger`:
+			engine.logger = value.(io.Writer)
+		case `pawncache`:
+			engine.pawnCacheSize = value.(int)
+		case `keepcache`:
+			engine.keepCache = value.(bool)
 		case `cache`:
 			switch value.(type) {
 			default: // :-)
@@ -81,17 +206,35 @@ func NewEngine(args ...interface{}) *Engine {
 	return &engine
 }
 
-// Dumps the string to standard output.
+// Returns custom command source if it was set or standard input otherwise.
+func (e *Engine) stdin() io.Reader {
+	if e.input != nil {
+		return e.input
+	}
+	return os.Stdin
+}
+
+// Dumps the string to standard output or custom output sink if it was set.
 func (e *Engine) print(arg string) *Engine {
+	if e.output != nil {
+		io.WriteString(e.output, arg)
+		return e
+	}
 	os.Stdout.WriteString(arg)
 	os.Stdout.Sync() // <-- Flush it.
 	return e
 }
 
-// Appends the string to log file. No flush is required as f.Write() and friends
-// are unbuffered.
+// Appends the string to log file or writes it to custom debug sink if it was
+// set. No flush is required as f.Write() and friends are unbuffered.
 func (e *Engine) debug(args ...interface{}) *Engine {
-	if len(e.logFile) != 0 {
+	if e.logger != nil {
+		if len := len(args); len > 1 {
+			fmt.Fprintf(e.logger, args[0].(string), args[1:]...)
+		} else {
+			io.WriteString(e.logger, args[0].(string))
+		}
+	} else if len(e.logFile) != 0 {
 		logFile, err := os.OpenFile(e.logFile, os.O_CREATE | os.O_WRONLY | os.O_APPEND, 0666)
 		if err == nil {
 			defer logFile.Close()
@@ -118,6 +261,177 @@ func (e *Engine) reply(args ...interface{}) *Engine {
 	return e
 }
 
+// Returns initial aspiration window delta and its growth factor.
+func (e *Engine) aspirationWindow() (delta, growth int) {
+	delta, growth = onePawn / 3, 2
+	if e.aspiration > 0 {
+		delta = e.aspiration
+	}
+	if e.widening > 1 {
+		growth = e.widening
+	}
+
+	return
+}
+
+// Returns weight applied on top of activity oriented evaluation terms, i.e.
+// mobility, king tropism, center control, and threats. Higher weight makes
+// the engine more dynamic, and lower weight makes it more materialistic.
+func (e *Engine) activityWeight() Score {
+	if e.activity > 0 {
+		return Score{ e.activity, e.activity }
+	}
+
+	return Score{ 100, 100 }
+}
+
+// Returns true if late quiet moves may be searched at reduced depth.
+func (e *Engine) reduceLateMoves() bool {
+	return !e.exactMode && !e.noReductions
+}
+
+// Returns true if null move pruning is allowed.
+func (e *Engine) nullMovePruning() bool {
+	return !e.exactMode && !e.noNullMove
+}
+
+// Returns the number of principal variations to search for. When limiting
+// strength or breaking ties we need a few extra lines to pick the move from.
+func (e *Engine) pvLines() int {
+	if e.limitStrength || e.tieBreak != tieBreakNone {
+		return max(e.multiPV, 4)
+	}
+
+	return e.multiPV
+}
+
+// Maps target elo rating to search depth limit and evaluation noise in
+// centipawns when limiting strength. The calibration is very approximate:
+// at 1000 the engine searches 1 ply with up to 3 pawns of noise, and at
+// the top of the range it plays at full strength.
+func (e *Engine) skill() (depth, noise int) {
+	if !e.limitStrength {
+		return MaxDepth, 0
+	}
+
+	elo := let(e.elo > 0, max(minElo, min(maxElo, e.elo)), maxElo)
+	if elo == maxElo {
+		return MaxDepth, 0
+	}
+
+	return 1 + (elo - minElo) / 150, (maxElo - elo) / 6
+}
+
+// Returns the number of plies quiescence search is allowed to go.
+func (e *Engine) quiescenceDepth() int {
+	if e.maxQDepth > 0 {
+		return e.maxQDepth
+	}
+
+	return defaultQDepth
+}
+
+// Returns true if quiescence search settings differ from the defaults.
+func (e *Engine) customQuiescence() bool {
+	return e.maxQDepth > 0 || e.noQChecks || e.noQPromos
+}
+
+// Returns the best move found so far along with its score for the side to
+// move and the last completed search depth. It's safe to call while the search
+// is going on, ex. from another goroutine.
+func (e *Engine) CurrentBest() (Move, int, int) {
+	e.progress.Lock(); defer e.progress.Unlock()
+	return e.progress.move, e.progress.score, e.progress.depth
+}
+
+// Publishes root search progress after completing the iteration.
+func (e *Engine) publish(move Move, score, depth int) *Engine {
+	e.progress.Lock(); defer e.progress.Unlock()
+	e.progress.move, e.progress.score, e.progress.depth = move, score, depth
+	return e
+}
+
+// Returns true if the search should go on until "ponderhit" or "stop" command.
+func (e *Engine) pondering() bool {
+	return e.options.ponder || e.options.infinite
+}
+
+// Returns true if the search should stop immediately. Both "ponderhit" and
+// clock ticks are picked up here so that the clock and search limits are only
+// ever touched by the search itself and not by the command loop.
+func (e *Engine) halted() bool {
+	if e.clock.hit.Load() && e.clock.hit.CompareAndSwap(true, false) {
+		e.options.ponder = false
+		if !e.fixedDepth() {
+			e.startTicker()
+		}
+	}
+	if e.clock.ticker != nil {
+		select {
+		case now := <-e.clock.ticker.C:
+			e.tick(now)
+		default:
+		}
+	}
+
+	return e.clock.halt.Load()
+}
+
+// Blocks while pondering or analyzing since the best move may not be reported
+// until GUI sends "ponderhit" or "stop" command.
+func (e *Engine) holdBestMove() *Engine {
+	if e.pondering() && e.clock.release != nil {
+		<-e.clock.release
+	}
+	return e
+}
+
+// Lets background search report its best move. Only the command loop calls
+// it so the channel gets closed exactly once.
+func (e *Engine) releaseBestMove() *Engine {
+	if e.clock.release != nil {
+		select {
+		case <-e.clock.release:
+		default:
+			close(e.clock.release)
+		}
+	}
+	return e
+}
+
+// Starts pondering or infinite analysis in the background so that the command
+// loop could still read the commands that end it.
+func (e *Engine) thinkInBackground() *Engine {
+	e.clock.halt.Store(false)
+	e.clock.hit.Store(false)
+	e.clock.release = make(chan struct{})
+
+	e.thinking.Add(1)
+	go func() {
+		defer e.thinking.Done()
+		game.Think()
+	}()
+
+	return e
+}
+
+// Switches pondering search over to regular timed search. The search goes on
+// with the same iteration using time limits given by "go ponder" command, and
+// the clock starts ticking as soon as the search notices the switch.
+func (e *Engine) ponderHit() *Engine {
+	e.clock.hit.Store(true)
+	if !e.options.infinite {
+		e.releaseBestMove()
+	}
+	return e
+}
+
+// Stops the search as soon as possible.
+func (e *Engine) stopThinking() *Engine {
+	e.clock.halt.Store(true)
+	return e.releaseBestMove()
+}
+
 func (e *Engine) fixedDepth() bool {
 	return e.options.maxDepth > 0
 }
@@ -153,11 +467,19 @@ func (e *Engine) factor(depth int, volatility float32) *Engine {
 	return e
 }
 
-// Starts the clock setting ticker callback function. The callback function is
-// different for fixed and variable time controls.
+// Starts the clock unless pondering or analyzing: background search gets its
+// halt flag reset by the command loop so that early "stop" doesn't get lost.
 func (e *Engine) startClock() *Engine {
-	e.clock.halt = false
+	if e.pondering() {
+		return e
+	}
+	e.clock.halt.Store(false)
 
+	return e.startTicker()
+}
+
+// Starts the ticker that makes the search check its time limits.
+func (e *Engine) startTicker() *Engine {
 	if e.options.moveTime == 0 && e.options.timeLeft == 0 {
 		return e
 	}
@@ -165,15 +487,10 @@ func (e *Engine) startClock() *Engine {
 	e.clock.start = time.Now()
 	e.clock.ticker = time.NewTicker(time.Millisecond * Ping)
 
-	if e.fixedTime() {
-		return e.fixedTimeTicker()
-	}
-
-	// How long a minute is depends on which side of the bathroom door you're on.
-	return e.varyingTimeTicker()
+	return e
 }
 
-// Stop the clock so that the ticker callback function is longer invoked.
+// Stop the clock so that the ticker is no longer checked.
 func (e *Engine) stopClock() *Engine {
 	if e.clock.ticker != nil {
 		e.clock.ticker.Stop()
@@ -182,47 +499,40 @@ func (e *Engine) stopClock() *Engine {
 	return e
 }
 
-// Ticker callback for fixed time control (ex. 5s per move). Search gets terminated
-// when we've got the move and the elapsed time approaches time-per-move limit.
-func (e *Engine) fixedTimeTicker() *Engine {
-	go func() {
-		if e.clock.ticker == nil {
-			return // Nothing to do if the clock has been stopped.
-		}
-		for now := range e.clock.ticker.C {
-			if game.rootpv.size == 0 {
-				continue // Haven't found the move yet.
-			}
-			if e.elapsed(now) >= e.options.moveTime - Ping {
-				e.clock.halt = true
-				return
-			}
-		}
-	}()
+// Checks time limits upon clock tick. The check is different for fixed and
+// variable time controls.
+func (e *Engine) tick(now time.Time) *Engine {
+	if game.rootpv.size == 0 {
+		return e // Haven't found the move yet.
+	}
+
+	if e.fixedTime() {
+		return e.fixedTimeTick(now)
+	}
+
+	// How long a minute is depends on which side of the bathroom door you're on.
+	return e.varyingTimeTick(now)
+}
+
+// Fixed time control (ex. 5s per move). Search gets terminated when we've got
+// the move and the elapsed time approaches time-per-move limit.
+func (e *Engine) fixedTimeTick(now time.Time) *Engine {
+	if e.elapsed(now) >= e.options.moveTime - Ping {
+		e.clock.halt.Store(true)
+	}
 
 	return e
 }
 
-// Ticker callback for the variable time control (ex. 40 moves in 5 minutes). Search
-// termination depends on multiple factors with hard stop being the ultimate limit.
-func (e *Engine) varyingTimeTicker() *Engine {
-	go func() {
-		if e.clock.ticker == nil {
-			return // Nothing to do if the clock has been stopped.
-		}
-		for now := range e.clock.ticker.C {
-			if game.rootpv.size == 0 {
-				continue // Haven't found the move yet.
-			}
-			elapsed := e.elapsed(now)
-			if (game.deepening && game.improving && elapsed > e.remaining() * 4 / 5) || elapsed > e.clock.hardStop {
-				//\\ e.debug("# Halt: Flags %v Elapsed %s Remaining %s Hard stop %s\n",
-				//\\	game.deepening && game.improving, ms(elapsed), ms(e.remaining() * 4 / 5), ms(e.clock.hardStop))
-				e.clock.halt = true
-				return
-			}
-		}
-	}()
+// Variable time control (ex. 40 moves in 5 minutes). Search termination depends
+// on multiple factors with hard stop being the ultimate limit.
+func (e *Engine) varyingTimeTick(now time.Time) *Engine {
+	elapsed := e.elapsed(now)
+	if (game.deepening && game.improving && elapsed > e.remaining() * 4 / 5) || elapsed > e.clock.hardStop {
+		//\\ e.debug("# Halt: Flags %v Elapsed %s Remaining %s Hard stop %s\n",
+		//\\	game.deepening && game.improving, ms(elapsed), ms(e.remaining() * 4 / 5), ms(e.clock.hardStop))
+		e.clock.halt.Store(true)
+	}
 
 	return e
 }
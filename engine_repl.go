@@ -27,32 +27,32 @@ var (
 )
 
 func (e *Engine) replBestMove(move Move) *Engine {
-	fmt.Printf(ansiTeal + "Donna's move: %s", move)
+	e.reply(ansiTeal + "Donna's move: %s", move)
 	if game.nodes == 0 {
-		fmt.Printf(" (book)")
+		e.reply(" (book)")
 	}
-	fmt.Println(ansiNone + "\n")
+	e.reply(ansiNone + "\n\n")
 
 	return e
 }
 
 func (e *Engine) replPrincipal(depth, score, status int, duration int64) {
-	fmt.Printf(`%2d %s %9d %9d %8.1fK %6.1f%%  `, depth, ms(duration), game.nodes, game.qnodes, float32(nps(duration)) / 1000.0, float32(hashfull()) / 10.0)
+	e.reply(`%2d %s %9d %9d %8.1fK %6.1f%%  `, depth, ms(duration), game.nodes, game.qnodes, float32(nps(duration)) / 1000.0, float32(hashfull()) / 10.0)
 	switch status {
 	case WhiteWon:
-		fmt.Println(`1-0 White Checkmates`)
+		e.reply("1-0 White Checkmates\n")
 	case BlackWon:
-		fmt.Println(`0-1 Black Checkmates`)
+		e.reply("0-1 Black Checkmates\n")
 	case Stalemate:
-		fmt.Println(`1/2 Stalemate`)
+		e.reply("1/2 Stalemate\n")
 	case Repetition:
-		fmt.Println(`1/2 Repetition`)
+		e.reply("1/2 Repetition\n")
 	case FiftyMoves:
-		fmt.Println(`1/2 Fifty Moves`)
+		e.reply("1/2 Fifty Moves\n")
 	case WhiteWinning, BlackWinning: // Show moves till checkmate.
-		fmt.Printf("%6dX   %v Checkmate\n", (Checkmate - abs(score)) / 2 + 1, game.rootpv.moves[0:game.rootpv.size])
+		e.reply("%6dX   %v Checkmate\n", (Checkmate - abs(score)) / 2 + 1, game.rootpv.moves[0:game.rootpv.size])
 	default:
-		fmt.Printf("%7.2f   %v\n", float32(score) / float32(onePawn), game.rootpv.moves[0:game.rootpv.size])
+		e.reply("%7.2f   %v\n", float32(score) / float32(onePawn), game.rootpv.moves[0:game.rootpv.size])
 	}
 }
 
@@ -173,7 +173,8 @@ func (e *Engine) Repl() *Engine {
 				"  new            Start new game\n" +
 				"  perft [depth]  Run perft test\n" +
 				"  score          Show evaluation summary\n" +
-				"  undo           Undo last move\n\n" +
+				"  undo           Undo last move\n" +
+				"  zobrist        Dump Zobrist keys table\n\n" +
 				"To make a move use algebraic notation, for example e2e4, Ng1f3, or e7e8Q\n")
 		case `new`:
 			game, position = nil, nil
@@ -184,6 +185,10 @@ func (e *Engine) Repl() *Engine {
 			setup()
 			_, metrics := position.EvaluateWithTrace()
 			Summary(metrics)
+		case `zobrist`:
+			for i, key := range DumpZobrist() {
+				fmt.Printf("%4d 0x%016X\n", i, key)
+			}
 		case `undo`:
 			if position != nil {
 				position = position.undoLastMove()
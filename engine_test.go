@@ -0,0 +1,400 @@
+// Copyright (c) 2014-2018 by Michael Dvorkin. All Rights Reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+//
+// I am making my contributions/submissions to this project solely in my
+// personal capacity and am not conveying any rights to any intellectual
+// property of any third parties.
+
+package donna
+
+import(`bufio`; `bytes`; `fmt`; `github.com/michaeldv/donna/expect`; `io`; `strconv`; `strings`; `testing`; `time`)
+
+// UCI output goes to custom output sink.
+func TestEngine000(t *testing.T) {
+	var output bytes.Buffer
+	NewEngine(`uci`, true, `depth`, 2, `output`, &output)
+	defer func() { engine = Engine{} }()
+
+	NewGame().start()
+	game.Think()
+
+	expect.True(t, strings.Contains(output.String(), "info depth 1 "))
+	expect.True(t, strings.Contains(output.String(), "\nbestmove "))
+}
+
+// Debug output goes to custom logger.
+func TestEngine010(t *testing.T) {
+	var logger bytes.Buffer
+	NewEngine(`logger`, &logger)
+	defer func() { engine = Engine{} }()
+
+	engine.debug("depth %d\n", 42).debug("done\n")
+	expect.Eq(t, logger.String(), "depth 42\ndone\n")
+}
+
+// First "xboard" command switches over to XBoard protocol.
+func TestEngine020(t *testing.T) {
+	var output bytes.Buffer
+	input := strings.NewReader("xboard\nprotover 2\nnew\nsd 2\nusermove e2e4\nping 7\nquit\n")
+	NewEngine(`input`, input, `output`, &output)
+	defer func() { engine = Engine{} }()
+
+	engine.Uci()
+	expect.False(t, engine.uci)
+	expect.True(t, engine.xboard)
+	expect.Contain(t, output.String(), "feature myname=\"Donna ")
+	expect.Contain(t, output.String(), " usermove=1 ")
+	expect.Contain(t, output.String(), " done=1\n")
+	expect.Contain(t, output.String(), "\nmove ")
+	expect.Contain(t, output.String(), "\npong 7\n")
+	expect.NotContain(t, output.String(), "bestmove")
+}
+
+// XBoard: force mode, setboard, and go.
+func TestEngine030(t *testing.T) {
+	var output bytes.Buffer
+	input := strings.NewReader("xboard\nforce\nsetboard 6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1\nsd 3\ngo\n")
+	NewEngine(`input`, input, `output`, &output)
+	defer func() { engine = Engine{} }()
+
+	engine.Uci()
+	expect.Eq(t, output.String(), "move a1a8\n")
+}
+
+// XBoard: no reply in force mode, illegal moves get rejected.
+func TestEngine040(t *testing.T) {
+	var output bytes.Buffer
+	input := strings.NewReader("xboard\nnew\nforce\nusermove e2e4\nusermove e7e5\nusermove e1e2\nusermove e2e5\nresult 1/2-1/2 {Draw}\n")
+	NewEngine(`input`, input, `output`, &output)
+	defer func() { engine = Engine{} }()
+
+	engine.Uci()
+	expect.Eq(t, output.String(), "Illegal move: e2e5\n")
+}
+
+// XBoard: thinking output and time controls.
+func TestEngine050(t *testing.T) {
+	var output bytes.Buffer
+	input := strings.NewReader("xboard\nnew\npost\nlevel 40 0:30 0\ntime 3000\notim 3000\nusermove d2d4\n")
+	NewEngine(`input`, input, `output`, &output)
+	defer func() { engine = Engine{} }()
+
+	engine.Uci()
+	expect.Eq(t, engine.options.movesToGo, int64(40))
+	expect.Eq(t, engine.options.timeLeft, int64(30000))
+	expect.True(t, strings.HasPrefix(output.String(), "1 "))
+	expect.Contain(t, output.String(), "\nmove ")
+}
+
+// UCI: Chess960 starting position by index and Shredder-FEN with castle rook
+// files, where the king on F1 castles kingside by capturing its G1 rook.
+func TestEngine060(t *testing.T) {
+	var output bytes.Buffer
+	input := strings.NewReader("position chess960 7\nquit\n")
+	NewEngine(`input`, input, `output`, &output)
+	defer func() { engine = Engine{}; NewGame().start() }()
+
+	engine.Uci()
+	p := game.position()
+	expect.True(t, engine.chess960)
+	expect.Eq(t, p.fen(), `qnbnrkrb/pppppppp/8/8/8/8/PPPPPPPP/QNBNRKRB w GEge - 0 1`)
+	expect.Eq(t, p.castles, uint8(0x0F))
+	expect.Eq(t, castleRook[White], [2]int{ G1, E1 })
+	expect.Eq(t, castleRook[Black], [2]int{ G8, E8 })
+
+	move := NewMoveFromNotation(p, `f1g1`)
+	expect.True(t, move.isCastle())
+	expect.True(t, NewMoveGen(p).generateAllMoves().validOnly().amongValid(move))
+
+	engine = Engine{ input: strings.NewReader("position fen qnbnrkrb/pppppppp/8/8/8/8/PPPPPPPP/QNBNRKRB w GEge - 0 1 moves f1g1\nquit\n"), output: &output }
+	engine.Uci()
+	p = game.position()
+	expect.True(t, engine.chess960)
+	expect.Eq(t, p.pieces[G1], Piece(King))
+	expect.Eq(t, p.pieces[F1], Piece(Rook))
+	expect.Eq(t, p.pieces[E1], Piece(Rook))
+	expect.Eq(t, p.castles, castleKingside[Black] | castleQueenside[Black])
+
+	expect.Eq(t, chess960Fen(518), `rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w HAha - 0 1`)
+	expect.Eq(t, chess960Fen(959, 0), `bbqnnrkr/pppppppp/8/8/8/8/PPPPPPPP/RKRNNQBB w CAhf - 0 1`)
+}
+
+// UCI: Chess960 castle rules implied by the position don't carry over to the
+// next position unless UCI_Chess960 option is on.
+func TestEngine065(t *testing.T) {
+	var output bytes.Buffer
+	input := strings.NewReader("position chess960 7\nposition startpos\nquit\n")
+	NewEngine(`input`, input, `output`, &output)
+	defer func() { engine = Engine{}; NewGame().start() }()
+
+	engine.Uci()
+	expect.False(t, engine.chess960)
+	expect.Eq(t, castleRook[White], [2]int{ H1, A1 })
+
+	engine.input = strings.NewReader("position fen qnbnrkrb/pppppppp/8/8/8/8/PPPPPPPP/QNBNRKRB w GEge - 0 1\nposition fen rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1\nquit\n")
+	engine.Uci()
+	expect.False(t, engine.chess960)
+
+	engine.input = strings.NewReader("setoption name UCI_Chess960 value true\nposition startpos\nquit\n")
+	engine.Uci()
+	expect.True(t, engine.chess960)
+}
+
+// Runs UCI session in the background. Returns command sink along with the
+// channel of engine replies that gets closed when the session is over.
+func uciSession() (io.WriteCloser, chan string) {
+	input, commands := io.Pipe()
+	replies, output := io.Pipe()
+	lines := make(chan string, 1024)
+
+	NewEngine(`input`, input, `output`, output, `cache`, 16)
+	go func() {
+		engine.Uci()
+		output.Close()
+	}()
+	go func() {
+		for scanner := bufio.NewScanner(replies); scanner.Scan(); {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	return commands, lines
+}
+
+// Collects engine replies until the one that starts with given prefix or until
+// the time runs out.
+func uciUntil(lines chan string, prefix string, timeout time.Duration) (replies []string, found bool) {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				return
+			}
+			replies = append(replies, line)
+			if strings.HasPrefix(line, prefix) {
+				return replies, true
+			}
+		case <-deadline:
+			return
+		}
+	}
+}
+
+// UCI: the best move is held back while pondering, and "ponderhit" turns
+// pondering into timed search that goes on without restarting from depth 1.
+func TestEngine070(t *testing.T) {
+	commands, lines := uciSession()
+	defer func() { engine = Engine{} }()
+
+	io.WriteString(commands, "setoption name Ponder value true\nposition startpos moves e2e4\ngo ponder wtime 10000 btime 10000\n")
+	pondering, found := uciUntil(lines, `bestmove`, 300 * time.Millisecond)
+	expect.False(t, found)
+	expect.True(t, len(pondering) > 0)
+	expect.Contain(t, pondering[0], `info depth 1 `)
+
+	io.WriteString(commands, "ponderhit\n")
+	searching, found := uciUntil(lines, `bestmove`, 5 * time.Second)
+	expect.True(t, found)
+	for _, line := range searching {
+		expect.False(t, strings.HasPrefix(line, `info depth 1 `))
+	}
+	expect.Contain(t, searching[len(searching) - 1], ` ponder `)
+
+	commands.Close()
+	for range lines {} // Wait till the session is over.
+}
+
+// UCI: "isready" gets answered while pondering, "stop" reports the best move,
+// and the search starts over once GUI sends the position actually played.
+func TestEngine080(t *testing.T) {
+	commands, lines := uciSession()
+	defer func() { engine = Engine{} }()
+
+	io.WriteString(commands, "position startpos moves e2e4\ngo ponder\nisready\n")
+	pondering, found := uciUntil(lines, `readyok`, 5 * time.Second)
+	expect.True(t, found)
+	for _, line := range pondering {
+		expect.NotContain(t, line, `bestmove`)
+	}
+
+	io.WriteString(commands, "stop\n")
+	_, found = uciUntil(lines, `bestmove`, 5 * time.Second)
+	expect.True(t, found)
+
+	io.WriteString(commands, "position startpos moves e2e4 c7c5\ngo depth 3\n")
+	searching, found := uciUntil(lines, `bestmove`, 5 * time.Second)
+	expect.True(t, found)
+	expect.Contain(t, searching[0], `info depth 1 `)
+	expect.NotContain(t, searching[len(searching) - 1], ` ponder `)
+
+	commands.Close()
+	for range lines {} // Wait till the session is over.
+}
+
+// Polling the best move while the search is going on.
+func TestEngine090(t *testing.T) {
+	NewEngine(`movetime`, 500, `cache`, 16, `output`, io.Discard)
+	defer func() { engine = Engine{} }()
+
+	p := NewGame(`r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3`).start()
+	done := make(chan Move)
+	go func() { done <- game.Think() }()
+
+	moves, depths := []Move{}, []int{}
+	for thinking := true; thinking; {
+		select {
+		case best := <-done:
+			thinking = false
+			move, _, _ := engine.CurrentBest()
+			expect.Eq(t, move, best)
+		case <-time.After(10 * time.Millisecond):
+			move, _, depth := engine.CurrentBest()
+			moves, depths = append(moves, move), append(depths, depth)
+		}
+	}
+
+	expect.True(t, depths[len(depths) - 1] > 1)
+	for i, move := range moves {
+		if i > 0 {
+			expect.True(t, depths[i] >= depths[i-1])
+		}
+		if move != 0 {
+			expect.True(t, NewGen(p, MaxPly).generateAllMoves().validOnly().amongValid(move))
+		}
+	}
+}
+
+// UCI: search time, nodes, speed, and cache usage are reported for each depth
+// and periodically during long searches.
+func TestEngine100(t *testing.T) {
+	var output bytes.Buffer
+	NewEngine(`uci`, true, `movetime`, 1500, `cache`, 16, `output`, &output)
+	defer func() { engine = Engine{} }()
+
+	NewGame(`r1bq1rk1/pp2bppp/2n1pn2/3p4/2PP4/2N2N2/PP2BPPP/R2QKB1R w KQ - 0 8`).start()
+	game.Think()
+
+	periodic, last := 0, map[string]int{}
+	for _, line := range strings.Split(output.String(), "\n") {
+		if !strings.Contains(line, ` nps `) {
+			continue
+		}
+		periodic += let(strings.HasPrefix(line, `info time `), 1, 0)
+		fields := strings.Fields(line)
+		values := map[string]int{}
+		for i := 0; i < len(fields) - 1; i++ {
+			if n, err := strconv.Atoi(fields[i+1]); err == nil {
+				values[fields[i]] = n
+			}
+		}
+		for _, field := range []string{ `time`, `nodes`, `nps`, `hashfull` } {
+			value, ok := values[field]
+			expect.True(t, ok)
+			if field != `nps` {
+				expect.True(t, value >= last[field])
+				last[field] = value
+			}
+		}
+	}
+
+	expect.True(t, periodic >= 2)
+	expect.True(t, last[`hashfull`] > 0 && last[`hashfull`] <= 1000)
+}
+
+// UCI: hidden quiescence search options, and the number of quiescence nodes
+// gets reported when they differ from the defaults.
+func TestEngine110(t *testing.T) {
+	var output bytes.Buffer
+	engine = Engine{ input: strings.NewReader("setoption name QSDepth value 6\nsetoption name QSChecks value false\nsetoption name QSPromotions value false\nposition startpos\ngo depth 3\nquit\n"), output: &output }
+	defer func() { engine = Engine{} }()
+	engine.Uci()
+
+	expect.Eq(t, engine.maxQDepth, 6)
+	expect.True(t, engine.noQChecks)
+	expect.True(t, engine.noQPromos)
+	expect.Contain(t, output.String(), `info string qnodes `)
+
+	output.Reset()
+	engine = Engine{ input: strings.NewReader("position startpos\ngo depth 3\nquit\n"), output: &output }
+	engine.Uci()
+	expect.NotContain(t, output.String(), `qnodes`)
+}
+
+// UCI: "go mate N" reports mate in N for either side, or no mate.
+func TestEngine120(t *testing.T) {
+	defer func() { engine = Engine{} }()
+	mate := func(fen string, moves int) string {
+		var output bytes.Buffer
+		engine = Engine{ input: strings.NewReader(fmt.Sprintf("position fen %s\ngo mate %d\nquit\n", fen, moves)), output: &output }
+		engine.Uci()
+		return output.String()
+	}
+
+	output := mate(`8/5Q2/8/8/6k1/5N2/5K2/8 w - - 0 1`, 2)
+	expect.Contain(t, output, ` score mate 2 `)
+	expect.Contain(t, output, "bestmove f7f6\n")
+
+	output = mate(`8/5k2/5n2/6K1/8/8/5q2/8 b - - 0 1`, 3) // Shortest mate is reported.
+	expect.Contain(t, output, ` score mate 2 `)
+	expect.Contain(t, output, "bestmove f2f3\n")
+
+	output = mate(`8/5Q2/8/8/6k1/5N2/5K2/8 w - - 0 1`, 1)
+	expect.Contain(t, output, "info string no mate in 1\nbestmove (none)\n")
+
+	output = mate(`rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1`, 2)
+	expect.Contain(t, output, "info string no mate in 2\nbestmove (none)\n")
+	expect.Eq(t, game.position().fen(), `rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1`)
+}
+
+// UCI: changing contempt clears the cache since cached draw scores depend on
+// it, and the draw score of the next search follows the new contempt.
+func TestEngine130(t *testing.T) {
+	var output bytes.Buffer
+	engine = Engine{ cacheSize: 32, input: strings.NewReader("position startpos\ngo depth 4\nsetoption name Contempt value 30\nquit\n"), output: &output }
+	defer func() { engine = Engine{} }()
+	engine.Uci()
+
+	used := 0
+	for i := range game.cache {
+		if game.cache[i].id != 0 {
+			used++
+		}
+	}
+	expect.Eq(t, used, 0)
+
+	output.Reset()
+	engine = Engine{ cacheSize: 32, input: strings.NewReader("position fen 6k1/8/8/8/8/8/8/1N4K1 w - - 0 1\ngo depth 4\nsetoption name Contempt value 30\ngo depth 4\nquit\n"), output: &output }
+	engine.Uci()
+	replies := strings.Split(output.String(), "bestmove")
+	expect.Contain(t, replies[0], ` score cp 0 `)
+	expect.Contain(t, replies[1], ` score cp -30 `)
+}
+
+// Invalid FEN keeps the previous position: UCI replies with info string, and
+// XBoard reports illegal position.
+func TestEngine140(t *testing.T) {
+	var output bytes.Buffer
+	engine = Engine{ input: strings.NewReader("position startpos moves e2e4\nposition fen 8/8/8 w - - 0 1\nquit\n"), output: &output }
+	defer func() { engine = Engine{} }()
+	engine.Uci()
+	expect.Eq(t, output.String(), "info string invalid FEN\n")
+	expect.Eq(t, game.position().fen(), `rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1`)
+
+	output.Reset()
+	engine = Engine{ input: strings.NewReader("xboard\nforce\nsetboard 6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1\nsetboard 6k1/5ppp/8 w - - 0 1\nsd 3\ngo\n"), output: &output }
+	engine.Uci()
+	expect.Eq(t, output.String(), "tellusererror Illegal position\nmove a1a8\n")
+}
+
+// UCI: "ponder" keeps time limits given before it.
+func TestEngine150(t *testing.T) {
+	engine = Engine{ input: strings.NewReader("position startpos\ngo wtime 10000 btime 9000 ponder test\nquit\n"), output: io.Discard }
+	defer func() { engine = Engine{} }()
+	engine.Uci()
+
+	expect.True(t, engine.options.ponder)
+	expect.Eq(t, engine.options.timeLeft, int64(10000))
+}
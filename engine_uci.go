@@ -12,13 +12,12 @@ import (
 	`bufio`
 	`fmt`
 	`io`
-	`os`
 	`strconv`
 	`strings`
 )
 
 func (e *Engine) uciScore(depth, score, alpha, beta int) *Engine {
-	str := fmt.Sprintf("info depth %d score", depth)
+	str := fmt.Sprintf("info depth %d seldepth %d score", depth, max(depth, game.selDepth))
 
 	if !isMate(score) {
 		str += fmt.Sprintf(" cp %d", score * 100 / onePawn)
@@ -38,16 +37,60 @@ func (e *Engine) uciScore(depth, score, alpha, beta int) *Engine {
 	return engine.reply(str + "\n")
 }
 
+func (e *Engine) uciStats(duration int64) *Engine {
+	return engine.reply("info time %d nodes %d nps %d hashfull %d\n", duration, game.nodes + game.qnodes, nps(duration), hashfull())
+}
+
 func (e *Engine) uciMove(move Move, moveno, depth int) *Engine {
 	return engine.reply("info depth %d currmove %s currmovenumber %d\n", depth, move.notation(), moveno)
 }
 
+// Reports the best move along with the expected reply to ponder on if the
+// move comes from the principal variation. While pondering the best move
+// is held back until "ponderhit" or "stop" command.
 func (e *Engine) uciBestMove(move Move, duration int64) *Engine {
+	e.holdBestMove()
+	if e.customQuiescence() {
+		engine.reply("info string qnodes %d\n", game.qnodes)
+	}
+	if e.ponder {
+		if reply := e.ponderMove(move); reply.some() {
+			return engine.reply("info nodes %d time %d\nbestmove %s ponder %s\n", game.nodes + game.qnodes, duration, move.notation(), reply.notation())
+		}
+	}
 	return engine.reply("info nodes %d time %d\nbestmove %s\n", game.nodes + game.qnodes, duration, move.notation())
 }
 
+// Returns the move to ponder on, i.e. the second move of principal variation.
+// If the principal variation got cut short we try the reply found in cache.
+func (e *Engine) ponderMove(move Move) Move {
+	if game.rootpv.size > 1 && game.rootpv.moves[0] == move {
+		return game.rootpv.moves[1]
+	}
+
+	position := game.position().makeMove(move)
+	defer position.undoLastMove()
+	if cached := position.probeCache(); cached != nil && cached.move.some() {
+		if NewGen(position, MaxPly).generateAllMoves().validOnly().amongValid(cached.move) {
+			return cached.move
+		}
+	}
+
+	return Move(0)
+}
+
 func (e *Engine) uciPrincipal(depth, score int, duration int64) *Engine {
-	str := fmt.Sprintf("info depth %d score", depth)
+	return e.uciLine(let(e.multiPV > 1, 1, 0), depth, score, duration, game.rootpv)
+}
+
+// Reports principal variation line. Non-zero k is the line number reported
+// as "multipv k" when we search for more than one line.
+func (e *Engine) uciLine(k, depth, score int, duration int64, line RootPv) *Engine {
+	str := fmt.Sprintf("info depth %d", depth)
+	if k > 0 {
+		str += fmt.Sprintf(" multipv %d", k)
+	}
+	str += " score"
 
 	if !isMate(score) {
 		str += fmt.Sprintf(" cp %d", score * 100 / onePawn)
@@ -60,15 +103,31 @@ func (e *Engine) uciPrincipal(depth, score int, duration int64) *Engine {
 	}
 	str += fmt.Sprintf(" nodes %d nps %d hashfull %d time %d pv", game.nodes + game.qnodes, nps(duration), hashfull(), duration)
 
-	for i := 0; i < game.rootpv.size; i++ {
-		str += " " + game.rootpv.moves[i].notation()
+	for i := 0; i < line.size; i++ {
+		str += " " + line.moves[i].notation()
 	}
 
 	return engine.reply(str + "\n")
 }
 
+// Reports refutation lines for all root moves except the best one.
+func (e *Engine) uciRefutations(best Move) *Engine {
+	for _, line := range game.refutations {
+		if line.moves[0] != best && line.size > 1 {
+			str := "info refutation"
+			for i := 0; i < line.size; i++ {
+				str += " " + line.moves[i].notation()
+			}
+			engine.reply(str + "\n")
+		}
+	}
+
+	return e
+}
+
 // Brain-damaged universal chess interface (UCI) protocol as described at
-// http://wbec-ridderkerk.nl/html/UCIProtocol.html
+// http://wbec-ridderkerk.nl/html/UCIProtocol.html. If the very first command
+// is "xboard" we switch over to XBoard protocol instead.
 func (e *Engine) Uci() *Engine {
 	var game *Game
 	var position *Position
@@ -81,6 +140,19 @@ func (e *Engine) Uci() *Engine {
 		e.reply("id name Donna %s\n", Version)
 		e.reply("id author Michael Dvorkin\n")
 		e.reply("option name Hash type spin default 256 min 32 max 1024\n")
+		e.reply("option name PreserveHash type check default false\n")
+		e.reply("option name PawnHash type spin default %d min 1 max 64\n", defaultPawnCache)
+		e.reply("option name OwnBook type check default true\n")
+		e.reply("option name BookFile type string default <empty>\n")
+		e.reply("option name BookBestMove type check default false\n")
+		e.reply("option name MultiPV type spin default 1 min 1 max 10\n")
+		e.reply("option name UCI_Chess960 type check default false\n")
+		e.reply("option name UCI_LimitStrength type check default false\n")
+		e.reply("option name UCI_Elo type spin default %d min %d max %d\n", maxElo, minElo, maxElo)
+		e.reply("option name TieBreak type combo default None var None var Tactical var Positional\n")
+		e.reply("option name Contempt type spin default 0 min -100 max 100\n")
+		e.reply("option name Ponder type check default false\n")
+		e.reply("option name UCI_ShowRefutations type check default false\n")
 		// e.reply("option name Mobility type spin default %d min 0 max 100\n", weightMobility.midgame)
 		// e.reply("option name PawnStructure type spin default %d min 0 max 100\n", weightPawnStructure.midgame)
 		// e.reply("option name PassedPawns type spin default %d min 0 max 100\n", weightPassedPawns.midgame)
@@ -99,32 +171,70 @@ func (e *Engine) Uci() *Engine {
 		e.reply("readyok\n")
 	}
 
-	// "position [startpos | fen ] [ moves ... ]" command handler.
+	// "position [startpos | fen | chess960 N [M] ] [ moves ... ]" command
+	// handler. Non-standard "chess960 N" sets up Chess960 starting position
+	// by its index, and optional M sets up black pieces for DFRC. FEN with
+	// castle rook files (Shredder-FEN) implies Chess960 castle rules for
+	// that position only unless UCI_Chess960 option is on.
 	doPosition := func(args []string) {
 		// Make sure we've started the game since "ucinewgame" is optional.
+		// Otherwise keep the game along with its transposition table: the
+		// positions are likely to be related, and stale cache entries from
+		// previous searches get expired by the cache token.
 		if game == nil || position == nil {
 			game = NewGame()
 		}
 
+		chess960, fen := e.chess960, ``
 		switch args[0] {
 		case `startpos`:
 			args = args[1:]
-			position = game.start()
+			e.chess960 = e.uciChess960
+			fen = `rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1`
 		case `fen`:
-			fen := []string{}
+			fields := []string{}
 			for _, token := range args[1:] {
 				args = args[1:] // Shift the token.
 				if token == `moves` {
 					break
 				}
-				fen = append(fen, token)
+				fields = append(fields, token)
 			}
-			game.initial = strings.Join(fen, ` `)
-			position = game.start()
+			e.chess960 = e.uciChess960 || (len(fields) > 2 && strings.ContainsAny(fields[2], `ABCDEFGHabcdefgh`))
+			fen = strings.Join(fields, ` `)
+		case `chess960`:
+			index := []int{}
+			for _, token := range args[1:] {
+				n, err := strconv.Atoi(token)
+				if err != nil || n < 0 || n > 959 || len(index) == 2 {
+					break
+				}
+				index = append(index, n)
+				args = args[1:] // Shift the index.
+			}
+			if len(index) == 0 {
+				return
+			}
+			args = args[1:] // Shift "chess960" token.
+			e.chess960 = true
+			fen = chess960Fen(index...)
 		default:
 			return
 		}
 
+		// Keep previous position if the new one is not valid, or start from
+		// the initial position if there was none.
+		if p := game.restart(fen); p != nil {
+			position = p
+		} else {
+			e.chess960 = chess960
+			e.reply("info string invalid FEN\n")
+			if position == nil {
+				position = game.start()
+			}
+			return
+		}
+
 		if position != nil && len(args) > 0 && args[0] == `moves` {
 			for _, move := range args[1:] {
 				args = args[1:] // Shift the move.
@@ -133,17 +243,18 @@ func (e *Engine) Uci() *Engine {
 		}
 	}
 
-	// "go [[wtime winc | btime binc ] movestogo] | depth | nodes | movetime"
+	// "go [[wtime winc | btime binc ] movestogo] | depth | nodes | mate | movetime"
 	doGo := func(args []string) {
 		think := true
 		options := e.options
+		options.ponder, options.infinite = false, false
 
 		for i, token := range args {
 			// Boolen "infinite" and "ponder" commands have no arguments.
 			if token == `infinite` {
-				options = Options{infinite: true}
+				options.infinite = true
 			} else if token == `ponder` {
-				options = Options{ponder: true}
+				options.ponder = true
 			} else if token == `test` { // <-- Custom token for use in tests.
 				think = false
 			} else if len(args) > i+1 {
@@ -156,6 +267,10 @@ func (e *Engine) Uci() *Engine {
 					if n, err := strconv.Atoi(args[i+1]); err == nil {
 						options = Options{ maxNodes: n }
 					}
+				case `mate`:
+					if n, err := strconv.Atoi(args[i+1]); err == nil && n > 0 {
+						options = Options{ mateIn: n }
+					}
 				case `movetime`:
 					if n, err := strconv.Atoi(args[i+1]); err == nil {
 						options = Options{ moveTime: int64(n) }
@@ -193,28 +308,121 @@ func (e *Engine) Uci() *Engine {
 		}
 		if options.timeLeft != 0 || options.timeInc != 0 || options.movesToGo != 0 {
 			e.varyingLimits(options)
+			e.options.ponder = options.ponder // Time limits kick in upon "ponderhit".
 		} else {
 			e.fixedLimit(options)
 		}
 
 		// Start "thinking" and come up with best move unless when running
-		// tests where we verify argument parsing only.
+		// tests where we verify argument parsing only. Pondering and infinite
+		// analysis go on in the background so that we could still read the
+		// commands that end them.
 		if think {
-			game.Think()
+			if e.pondering() {
+				e.thinkInBackground()
+			} else {
+				e.clock.release = nil
+				game.Think()
+			}
 		}
 	}
 
+	// "ponderhit" command handler: the opponent has played the expected move
+	// so we carry on searching with regular time limits.
+	doPonderHit := func(args []string) {
+		e.ponderHit()
+	}
+
 	// Stop calculating as soon as possible.
 	doStop := func(args []string) {
-		e.clock.halt = true
+		e.stopThinking()
 	}
 
-	// Set UCI option. So far we only support "setoption name Hash value 32..1024".
+	// "setoption name <name> value <value>" command handler. Options that are
+	// not reported by "uci" command are marked as hidden.
 	doSetOption := func(args []string) {
-		if len(args) == 4 && args[0] == `name` && args[1] == `Hash` && args[2] == `value` {
-			if n, err := strconv.Atoi(args[3]); err == nil && n >= 32 && n <= 1024 {
-				e.cacheSize = float64(n)
+		if len(args) == 4 && args[0] == `name` && args[2] == `value` {
+			switch args[1] {
+			case `Hash`: // Cache size, 32..1024 megabytes.
+				if n, err := strconv.Atoi(args[3]); err == nil && n >= 32 && n <= 1024 {
+					e.cacheSize = float64(n)
+					if e.keepCache && game != nil {
+						game.rehashCache(e.cacheSize)
+					} else {
+						game, position = nil, nil // Make sure the game gets restarted.
+					}
+				}
+			case `PreserveHash`: // Keep cache entries when resizing.
+				e.keepCache = (args[3] == `true`)
+			case `PawnHash`: // Pawn cache size, 1..64 megabytes.
+				if n, err := strconv.Atoi(args[3]); err == nil && n >= 1 && n <= 64 {
+					e.pawnCacheSize = n
+					game, position = nil, nil // Make sure the game gets restarted.
+				}
+			case `OwnBook`: // Consult the opening book.
+				e.noBook = (args[3] == `false`)
+			case `BookFile`: // Polyglot book path, or <empty> for none.
+				e.bookFile = `` // Missing book file means no book.
+				if _, err := NewBook(args[3]); err == nil {
+					e.bookFile = args[3]
+				} else if args[3] != `<empty>` {
+					e.reply("info string %s\n", err)
+				}
+			case `BookBestMove`: // Always play the book move with the highest weight.
+				e.bookBest = (args[3] == `true`)
+			case `MultiPV`: // Number of principal variations, 1..10.
+				if n, err := strconv.Atoi(args[3]); err == nil && n >= 1 && n <= 10 {
+					e.multiPV = n
+				}
+			case `UCI_Chess960`: // Chess960 castle rules.
+				e.uciChess960 = (args[3] == `true`)
+				e.chess960 = e.uciChess960
 				game, position = nil, nil // Make sure the game gets restarted.
+			case `UCI_LimitStrength`: // Play at roughly the UCI_Elo rating.
+				e.limitStrength = (args[3] == `true`)
+			case `UCI_Elo`: // Target rating, 1000..2800.
+				if n, err := strconv.Atoi(args[3]); err == nil && n >= minElo && n <= maxElo {
+					e.elo = n
+				}
+			case `TieBreak`: // Near-equal root moves policy: None|Tactical|Positional.
+				e.tieBreak = map[string]int{ `Tactical`: tieBreakTactical, `Positional`: tieBreakPositional }[args[3]]
+			case `Contempt`: // Draw score offset, -100..100 centipawns.
+				if n, err := strconv.Atoi(args[3]); err == nil && n >= -100 && n <= 100 {
+					if n != e.contempt && game != nil {
+						game.cache = NewCache(e.cacheSize) // Cached draw scores depend on contempt.
+					}
+					e.contempt = n
+				}
+			case `Ponder`: // Report expected reply to ponder on.
+				e.ponder = (args[3] == `true`)
+			case `LMR`: // Hidden: toggles late move reductions.
+				e.noReductions = (args[3] == `false`)
+			case `NullMove`: // Hidden: toggles null move pruning.
+				e.noNullMove = (args[3] == `false`)
+			case `QSDepth`: // Hidden: quiescence search depth limit.
+				if n, err := strconv.Atoi(args[3]); err == nil && n >= 0 && n <= MaxPly {
+					e.maxQDepth = n
+				}
+			case `QSChecks`: // Hidden: checks in quiescence search (evasions are always searched).
+				e.noQChecks = (args[3] == `false`)
+			case `QSPromotions`: // Hidden: non-capturing queen promotions in quiescence search.
+				e.noQPromos = (args[3] == `false`)
+			case `UCI_ShowRefutations`: // Report refutation lines.
+				e.refutations = (args[3] == `true`)
+			}
+		}
+	}
+
+	// "perft N" command handler: non-standard command that prints the number of
+	// leaf nodes for each root move of the current position followed by total.
+	doPerft := func(args []string) {
+		if game == nil || position == nil {
+			game = NewGame()
+			position = game.start()
+		}
+		if len(args) > 0 {
+			if depth, err := strconv.Atoi(args[0]); err == nil && depth > 0 {
+				e.reply("\nNodes searched: %d\n", position.Divide(depth))
 			}
 		}
 	}
@@ -226,7 +434,9 @@ func (e *Engine) Uci() *Engine {
 		`position`:   doPosition,
 		`go`:         doGo,
 		`stop`:       doStop,
+		`ponderhit`:  doPonderHit,
 		`setoption`:  doSetOption,
+		`perft`:      doPerft,
 	}
 
 	// I/O, I/O,
@@ -234,19 +444,41 @@ func (e *Engine) Uci() *Engine {
 	// a bit or byte to read or write,
 	// I/O, I/O, I/O, I/O
 	//                -- Dave Peacock
-	bio := bufio.NewReader(os.Stdin)
-	for {
+	bio := bufio.NewReader(e.stdin())
+	for first := true; ; {
 		command, err := bio.ReadString('\n')
-		if err != io.EOF && len(command) > 0 {
+		if len(command) > 0 {
 			//\\ e.debug("> " + command)
 			args := strings.Split(strings.Trim(command, " \t\r\n"), ` `)
 			if args[0] == `quit` {
 				break
 			}
+			// Switch over to XBoard protocol if that's what the GUI speaks.
+			if first && args[0] == `xboard` {
+				e.uci = false
+				return e.xboardLoop(bio)
+			}
+			// Let background search finish before handling the commands
+			// that might change the position or start another search.
+			if args[0] != `stop` && args[0] != `ponderhit` && args[0] != `isready` {
+				e.thinking.Wait()
+			}
 			if handler, ok := commands[args[0]]; ok {
 				handler(args[1:])
 			}
+			first = false
+		}
+		if err == io.EOF {
+			break
 		}
 	}
+
+	// Stop pondering or infinite analysis if any, and wait till the search
+	// reports the best move.
+	if e.clock.release != nil {
+		e.stopThinking()
+	}
+	e.thinking.Wait()
+
 	return e
 }
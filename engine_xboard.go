@@ -0,0 +1,269 @@
+// Copyright (c) 2014-2018 by Michael Dvorkin. All Rights Reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+//
+// I am making my contributions/submissions to this project solely in my
+// personal capacity and am not conveying any rights to any intellectual
+// property of any third parties.
+
+package donna
+
+import (
+	`bufio`
+	`fmt`
+	`io`
+	`strconv`
+	`strings`
+)
+
+func (e *Engine) xboardMove(move Move) *Engine {
+	return engine.reply("move %s\n", move.notation())
+}
+
+// Reports thinking output as "ply score time nodes pv" where time is measured
+// in centiseconds. Mate in N moves gets reported as 100000 + N.
+func (e *Engine) xboardPrincipal(depth, score int, duration int64) *Engine {
+	if !e.post {
+		return e
+	}
+
+	if isMate(score) {
+		if score > 0 {
+			score = 100000 + (Checkmate - score + 1) / 2
+		} else {
+			score = -100000 - (Checkmate + score) / 2
+		}
+	} else {
+		score = score * 100 / onePawn
+	}
+	str := fmt.Sprintf("%d %d %d %d", depth, score, duration / 10, game.nodes + game.qnodes)
+
+	for i := 0; i < game.rootpv.size; i++ {
+		str += " " + game.rootpv.moves[i].notation()
+	}
+
+	return engine.reply(str + "\n")
+}
+
+// Chess engine communication protocol (XBoard/WinBoard) as described at
+// https://www.gnu.org/software/xboard/engine-intf.html
+func (e *Engine) Xboard() *Engine {
+	return e.xboardLoop(bufio.NewReader(e.stdin()))
+}
+
+// Runs XBoard command loop reading commands from the given reader. The search
+// and position objects are the same ones UCI uses; we only translate moves
+// and time controls.
+func (e *Engine) xboardLoop(bio *bufio.Reader) *Engine {
+	var game *Game
+	var position *Position
+
+	e.uci, e.xboard = false, true
+
+	force := false         // Don't think, just make the moves we're given.
+	ours := Black          // The color the engine is playing.
+	played := int64(0)     // Number of moves made by the engine since "new".
+	perSession := int64(0) // Number of moves per time control ("level").
+	timeLeft, timeInc := int64(0), int64(0)
+	moveTime, maxDepth := int64(0), 0
+	defaults := e.options
+
+	setup := func() {
+		if game == nil || position == nil {
+			game = NewGame()
+			position = game.start()
+		}
+	}
+
+	// Sets search limits and comes up with the best move. The move gets
+	// reported by the game itself, and we play it on the board.
+	think := func() {
+		switch {
+		case maxDepth > 0:
+			e.fixedLimit(Options{ maxDepth: maxDepth })
+		case moveTime > 0:
+			e.fixedLimit(Options{ moveTime: moveTime })
+		case timeLeft > 0 || timeInc > 0:
+			options := Options{ timeLeft: timeLeft, timeInc: timeInc }
+			if perSession > 0 {
+				options.movesToGo = perSession - played % perSession
+			}
+			e.varyingLimits(options)
+		default:
+			e.fixedLimit(defaults)
+		}
+
+		if move := game.Think(); move.some() {
+			position = position.makeMove(move)
+			played++
+		}
+	}
+
+	// "protover N" command handler.
+	doProtover := func(args []string) {
+		e.reply("feature myname=\"Donna %s\" usermove=1 setboard=1 ping=1 colors=0 sigint=0 sigterm=0 analyze=0 done=1\n", Version)
+	}
+
+	// "new" command handler: reset the board, the engine plays black.
+	doNew := func(args []string) {
+		game, position = nil, nil
+		setup()
+		force, ours, played, maxDepth = false, Black, 0, 0
+	}
+
+	// "setboard FEN" command handler: illegal position leaves the board as
+	// it was.
+	doSetboard := func(args []string) {
+		setup()
+		if p := game.restart(strings.Join(args, ` `)); p != nil {
+			position = p
+		} else {
+			e.reply("tellusererror Illegal position\n")
+		}
+	}
+
+	// "force" and "result ..." command handlers: stop thinking, just make
+	// the moves we're given.
+	doForce := func(args []string) {
+		force = true
+	}
+
+	// "go" command handler: play the side to move and start thinking.
+	doGo := func(args []string) {
+		setup()
+		force, ours = false, position.color
+		think()
+	}
+
+	// "playother" command handler: play the side that is not on move.
+	doPlayOther := func(args []string) {
+		setup()
+		force, ours = false, position.color ^ 1
+	}
+
+	// "usermove MOVE" command handler: make the move and think in reply
+	// unless in force mode.
+	doUserMove := func(args []string) {
+		setup()
+		if len(args) == 0 {
+			return
+		}
+		move, _ := NewMoveFromString(position, args[0])
+		if move == 0 {
+			e.reply("Illegal move: %s\n", args[0])
+			return
+		}
+		position = position.makeMove(move)
+		if !force && position.color == ours {
+			think()
+		}
+	}
+
+	// "undo" and "remove" command handlers take back one and two moves.
+	undo := func(moves int) func([]string) {
+		return func(args []string) {
+			for i := 0; i < moves && position != nil && node > 0; i++ {
+				position = position.undoLastMove()
+			}
+		}
+	}
+
+	// "level MPS BASE INC" command handler where BASE is either minutes or
+	// "minutes:seconds" and INC is in seconds.
+	doLevel := func(args []string) {
+		if len(args) < 3 {
+			return
+		}
+		base := strings.Split(args[1], `:`)
+		mps, _ := strconv.Atoi(args[0])
+		minutes, _ := strconv.Atoi(base[0])
+		seconds := 0
+		if len(base) > 1 {
+			seconds, _ = strconv.Atoi(base[1])
+		}
+		inc, _ := strconv.ParseFloat(args[2], 64)
+
+		perSession, played, moveTime = int64(mps), 0, 0
+		timeLeft = int64(minutes * 60 + seconds) * 1000
+		timeInc = int64(inc * 1000)
+	}
+
+	// "st SECONDS" command handler: fixed time per move.
+	doSt := func(args []string) {
+		if len(args) > 0 {
+			if n, err := strconv.Atoi(args[0]); err == nil {
+				moveTime, timeLeft, timeInc = int64(n) * 1000, 0, 0
+			}
+		}
+	}
+
+	// "sd DEPTH" command handler.
+	doSd := func(args []string) {
+		if len(args) > 0 {
+			if n, err := strconv.Atoi(args[0]); err == nil {
+				maxDepth = n
+			}
+		}
+	}
+
+	// "time N" command handler: engine's clock in centiseconds.
+	doTime := func(args []string) {
+		if len(args) > 0 {
+			if n, err := strconv.Atoi(args[0]); err == nil {
+				timeLeft = int64(n) * 10
+			}
+		}
+	}
+
+	// "ping N" command handler.
+	doPing := func(args []string) {
+		e.reply("pong %s\n", strings.Join(args, ` `))
+	}
+
+	var commands = map[string]func([]string){
+		`xboard`:    func(args []string) {},
+		`accepted`:  func(args []string) {},
+		`rejected`:  func(args []string) {},
+		`otim`:      func(args []string) {},
+		`random`:    func(args []string) {},
+		`hard`:      func(args []string) {},
+		`easy`:      func(args []string) {},
+		`computer`:  func(args []string) {},
+		`protover`:  doProtover,
+		`new`:       doNew,
+		`setboard`:  doSetboard,
+		`force`:     doForce,
+		`result`:    doForce,
+		`go`:        doGo,
+		`playother`: doPlayOther,
+		`usermove`:  doUserMove,
+		`undo`:      undo(1),
+		`remove`:    undo(2),
+		`level`:     doLevel,
+		`st`:        doSt,
+		`sd`:        doSd,
+		`time`:      doTime,
+		`ping`:      doPing,
+		`post`:      func(args []string) { e.post = true },
+		`nopost`:    func(args []string) { e.post = false },
+	}
+
+	for {
+		command, err := bio.ReadString('\n')
+		if len(command) > 0 {
+			args := strings.Split(strings.Trim(command, " \t\r\n"), ` `)
+			if args[0] == `quit` {
+				break
+			}
+			if handler, ok := commands[args[0]]; ok {
+				handler(args[1:])
+			} else if args[0] != `` {
+				e.reply("Error (unknown command): %s\n", args[0])
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	return e
+}
@@ -8,6 +8,8 @@
 
 package donna
 
+import `fmt`
+
 // const = brains * looks * availability
 const (
 	whiteKingSafety    = 0x01  // Should we worry about white king's safety?
@@ -61,11 +63,33 @@ type Evaluation struct {
 var eval Evaluation
 
 // The following statement is true. The previous statement is false. Main position
-// evaluation method that returns single blended score.
+// evaluation method that returns single blended score. The score is pure static
+// evaluation: draw adjustments belong to the search, so that the score could be
+// cached without depending on search settings. The only exceptions are dead
+// drawn material and the fifty moves rule that return exact draw score.
 func (p *Position) Evaluate() int {
+	if p.insufficientMaterial() || p.fifty() {
+		return DrawScore
+	}
+
 	return eval.init(p).run()
 }
 
+// Evaluates the position after the given move without changing the position
+// itself. The move gets made in the next tree node, evaluated, and taken back.
+// Returns the score from the point of view of the side making the move, or an
+// error if the move is not legal.
+func (p *Position) EvaluateAfter(move Move) (int, error) {
+	if !NewGen(p, MaxPly).generateAllMoves().validOnly().amongValid(move) {
+		return 0, fmt.Errorf(`illegal move %s`, move.str())
+	}
+
+	position := p.makeMove(move)
+	defer position.undoLastMove()
+
+	return -position.Evaluate(), nil
+}
+
 // Auxiliary evaluation method that captures individual evaluation metrics. This
 // is useful when we want to see evaluation summary.
 func (p *Position) EvaluateWithTrace() (int, Metrics) {
@@ -134,6 +158,7 @@ func (e *Evaluation) run() int {
 	}
 
 	e.analyzePawns()
+	e.analyzeLevers()
 	e.analyzePieces()
 	e.analyzeThreats()
 	e.analyzeSafety()
@@ -143,6 +168,33 @@ func (e *Evaluation) run() int {
 	return e.score.blended(e.material.phase)
 }
 
+// Calculates the score of a single evaluation term: `pawns`, `levers`, `pieces`,
+// `threats`, `safety`, or `passers`. The terms preceding the requested one get
+// run too since they set up data it relies on (ex. attack bitmasks), but their
+// scores are not included. The returned score is from white's point of view.
+func (e *Evaluation) RunTerm(name string) (score Score) {
+	e.material = &materialBase[e.position.balance]
+
+	terms := []struct{ name string; run func() }{
+		{ `pawns`,   e.analyzePawns   },
+		{ `levers`,  e.analyzeLevers  },
+		{ `pieces`,  e.analyzePieces  },
+		{ `threats`, e.analyzeThreats },
+		{ `safety`,  e.analyzeSafety  },
+		{ `passers`, e.analyzePassers },
+	}
+
+	for _, term := range terms {
+		before := e.score
+		term.run()
+		if term.name == name {
+			return *score.add(e.score).sub(before)
+		}
+	}
+
+	return
+}
+
 func (e *Evaluation) wrapUp() {
 
 	// Adjust the endgame score if we have lesser known endgame.
@@ -55,8 +55,75 @@ func (e *Evaluation) strongerSide() int {
 }
 
 // Known endgames where we calculate the exact score.
-func (e *Evaluation) winAgainstBareKing() int { 	// STUB.
-	return e.score.blended(e.material.phase)
+func (e *Evaluation) winAgainstBareKing() int {
+	p := e.position
+	our := e.strongerSide()
+	their := our^1
+	king := p.king[their]
+
+	// Count bare king's flight squares. Note that the bare king gets
+	// removed from the board so it doesn't block sliding attacks.
+	flights, board := 0, p.board ^ bit[king]
+	for bm := kingMoves[king]; bm.any(); bm = bm.pop() {
+		if p.attackers(our, bm.first(), board).empty() {
+			flights++
+		}
+	}
+
+	// The bare king can't move and is not in check: it's a stalemate.
+	inCheck := p.isInCheck(their)
+	if flights == 0 && !inCheck && p.color == their {
+		return DrawScore
+	}
+
+	// Drive the bare king towards the edge of the board and bring our king
+	// closer to it.
+	row, col := coordinate(king)
+	edge := 3 - min(min(row, 7 - row), min(col, 7 - col))
+	bonus := edge * 20 + (7 - distance[p.king[our]][king]) * 10
+
+	// Small nudge to keep the bare king with at least one legal move until
+	// the mate is set up.
+	if flights == 0 && !inCheck {
+		bonus -= 20
+	}
+
+	// Measure progress by the space the bare king is confined to: shrinking
+	// the box keeps the engine from shuffling when the king can't be pushed
+	// to the edge or approached in a single move.
+	bonus -= p.kingSpace(their)
+
+	if our == White {
+		return e.score.blended(e.material.phase) + bonus
+	}
+	return e.score.blended(e.material.phase) - bonus
+}
+
+// Returns the number of squares the king could reach walking over squares not
+// attacked by the opponent. The king itself is removed from the board so that
+// it doesn't block sliding attacks.
+func (p *Position) kingSpace(color int) int {
+	king := p.king[color]
+	board := p.board ^ bit[king]
+
+	safe := Bitmask(0)
+	for square := A1; square <= H8; square++ {
+		if p.attackers(color^1, square, board).empty() {
+			safe |= bit[square]
+		}
+	}
+
+	area := bit[king]
+	for reach := area; reach.any(); {
+		next := Bitmask(0)
+		for bm := reach; bm.any(); bm = bm.pop() {
+			next |= kingMoves[bm.first()]
+		}
+		reach = next & safe & ^area
+		area |= reach
+	}
+
+	return area.count()
 }
 
 func (e *Evaluation) knightAndBishopVsBareKing() int {	// STUB.
@@ -68,23 +135,8 @@ func (e *Evaluation) twoBishopsVsBareKing() int { 	// STUB.
 }
 
 func (e *Evaluation) kingAndPawnVsBareKing() int {
-	var color, wKing, bKing, wPawn int
-
 	stronger := e.strongerSide()
-	if stronger == White {
-		color = e.position.color
-		wKing = e.position.king[White]
-		bKing = e.position.king[Black]
-		wPawn = e.position.outposts[Pawn].last()
-	} else {
-		color = e.position.color ^ 1
-		wKing = 64 + ^e.position.king[Black]
-		bKing = 64 + ^e.position.king[White]
-		wPawn = 64 + ^e.position.outposts[BlackPawn].last()
-	}
-
-	index := color + (wKing << 1) + (bKing << 7) + ((wPawn - 8) << 13)
-	if (bitbase[index / 64] & bit[index & 0x3F]).empty() {
+	if !e.position.kpkWinning(stronger) {
 		return DrawScore
 	}
 
@@ -95,6 +147,32 @@ func (e *Evaluation) kingAndPawnVsBareKing() int {
 	return WhiteWinning
 }
 
+// Looks up KPK bitbase and returns true if the stronger side that has the pawn
+// is winning.
+func (p *Position) kpkWinning(stronger int) bool {
+	var color, wKing, bKing, wPawn int
+
+	if stronger == White {
+		color = p.color
+		wKing = p.king[White]
+		bKing = p.king[Black]
+		wPawn = p.outposts[Pawn].last()
+	} else {
+		color = p.color ^ 1
+		wKing = 64 + ^p.king[Black]
+		bKing = 64 + ^p.king[White]
+		wPawn = 64 + ^p.outposts[BlackPawn].last()
+	}
+
+	return kpkLookup(color, wKing, bKing, wPawn)
+}
+
+// Returns true if white wins KPK bitbase position with given side to move.
+func kpkLookup(color, wKing, bKing, wPawn int) bool {
+	index := color + (wKing << 1) + (bKing << 7) + ((wPawn - 8) << 13)
+	return (bitbase[index / 64] & bit[index & 0x3F]).any()
+}
+
 // Lesser known endgames where we calculate endgame score markdown.
 func (e *Evaluation) kingAndPawnsVsBareKing() int {
 	color := e.strongerSide()
@@ -167,6 +245,16 @@ func (e *Evaluation) kingAndPawnVsKingAndPawn() int {
 	// Check if either side has unstoppable pawn.
 	white := unstoppable(White, p.outposts[pawn(White)].first())
 	black := unstoppable(Black, p.outposts[pawn(Black)].first())
+	if white && black {
+		// Both pawns are unstoppable: let the pawn race decide.
+		switch e.pawnRace() {
+		case White:
+			e.score.endgame = WhiteWinning
+		case Black:
+			e.score.endgame = BlackWinning
+		}
+		return ExistingScore
+	}
 	if white {
 		e.score.endgame = WhiteWinning
 	}
@@ -213,11 +301,110 @@ func (e *Evaluation) kingAndPawnVsKingAndPawn() int {
 	return ExistingScore
 }
 
-func (e *Evaluation) bishopAndPawnVsBareKing() int {	// STUB.
+// Pawn endgame with pawns on both sides. When the side to move has no spare
+// pawn moves to lose a tempo the king holding the opposition gets the upper
+// hand: the other king has to step aside and let it through.
+func (e *Evaluation) kingAndPawnsVsKingAndPawns() int {
+	p := e.position
+	color := e.opposition()
+
+	if color < 0 || (p.outposts[pawn(color^1)].up(color^1) & ^p.board).any() {
+		return ExistingScore
+	}
+
+	// Direct opposition counts in full, distant opposition counts for half.
+	bonus := opposition.endgame
+	if distance[p.king[White]][p.king[Black]] > 2 {
+		bonus /= 2
+	}
+
+	if color == White {
+		e.score.endgame += bonus
+	} else {
+		e.score.endgame -= bonus
+	}
+
+	return ExistingScore
+}
+
+// Returns the side holding the opposition, i.e. the side that has just moved
+// when the kings stand on the same file, rank, or diagonal with odd number of
+// squares between them. Returns -1 if neither side holds the opposition.
+func (e *Evaluation) opposition() int {
+	p := e.position
+	rows := abs(row(p.king[White]) - row(p.king[Black]))
+	cols := abs(col(p.king[White]) - col(p.king[Black]))
+
+	if (rows == 0 || cols == 0 || rows == cols) && max(rows, cols) % 2 == 0 {
+		return p.color^1
+	}
+
+	return -1
+}
+
+// Bishop and rook pawn vs. bare king: draw if the bishop doesn't control
+// the promotion square and the defending king holds the corner.
+func (e *Evaluation) bishopAndPawnVsBareKing() int {
+	p, color := e.position, e.strongerSide()
+
+	if passer := p.outposts[pawn(color)].first(); col(passer) == A1A8 || col(passer) == H1H8 {
+		corner := square(let(color == White, A8H8, A1H1), col(passer))
+		if (p.outposts[bishop(color)] & same(corner)).empty() && distance[p.king[color^1]][corner] <= 1 {
+			return DrawScore
+		}
+	}
+
 	return ExistingScore
 }
 
-func (e *Evaluation) rookAndPawnVsRook() int {		// STUB.
+// Rook and pawn vs. rook: our rook cutting off the enemy king from the passer
+// is the key winning mechanism (ex. Lucena position). With enemy king in front
+// of the passer the endgame is drawish, and Philidor and back rank defenses
+// are known draws.
+func (e *Evaluation) rookAndPawnVsRook() int {
+	p := e.position
+	our := let(p.outposts[Pawn].any(), White, Black)
+	their := our^1
+
+	passer, from, king := p.outposts[pawn(our)].first(), p.outposts[rook(our)].first(), p.king[their]
+
+	if maskInFront[our][passer].on(king) {
+		defender := p.outposts[rook(their)].first()
+
+		// Philidor: the rook holds the 3rd rank (6th from our side) until
+		// the passer steps onto it, and then checks from behind.
+		if rank(our, passer) < A6H6 && rank(our, defender) == A6H6 {
+			return DrawScore
+		}
+
+		// Back rank defense: the king on the promotion square, and the rook
+		// on the back rank hold against knight and rook pawns.
+		if rank(our, king) == A8H8 && rank(our, defender) == A8H8 && (col(passer) <= B1 || col(passer) >= G1) {
+			return DrawScore
+		}
+
+		return e.fraction(1, 2)
+	}
+
+	// The king is cut off if our rook's file separates it from the passer,
+	// or if the rook's rank separates it from the promotion square.
+	files := (col(king) - col(from)) * (col(passer) - col(from)) < 0
+	ranks := rank(our, king) < rank(our, from) && rank(our, passer) <= rank(our, from)
+
+	if files || ranks {
+		bonus := rookCutOff.endgame
+		if files {
+			bonus += (abs(col(king) - col(from)) - 1) * rookCutOff.endgame / 2 // Farther is better.
+
+			// Lucena: the passer on 7th rank, our king on the promotion square
+			// and enemy king cut off by our rook. The win is building a bridge.
+			if rank(our, passer) == A7H7 && p.king[our] == passer + up[our] {
+				bonus += rookLucena.endgame
+			}
+		}
+		e.score.endgame += let(our == White, bonus, -bonus)
+	}
+
 	return ExistingScore
 }
 
@@ -225,6 +412,33 @@ func (e *Evaluation) queenVsRookAndPawns() int { 	// STUB.
 	return ExistingScore
 }
 
+// Equal heavy pieces and a locked pawn wall: every pawn is blocked, there are
+// no pawn captures to open the position, and no open files for the rooks and
+// queens to penetrate through. Neither side can make progress so we scale down
+// both midgame and endgame scores.
+func (e *Evaluation) blockedPawnWall() int {
+	p := e.position
+	white, black := p.outposts[Pawn], p.outposts[BlackPawn]
+	pawns := white | black
+
+	if (white.up(White) & ^pawns).any() || (black.up(Black) & ^pawns).any() {
+		return ExistingScore
+	}
+	if (p.pawnAttacks(White) & black).any() || (p.pawnAttacks(Black) & white).any() {
+		return ExistingScore
+	}
+	for col := 0; col < 8; col++ {
+		if (pawns & maskFile[col]).empty() {
+			return ExistingScore
+		}
+	}
+
+	e.score.midgame /= 8
+	e.score.endgame /= 8
+
+	return ExistingScore
+}
+
 // One side has 1 pawn and the other side has 1 or more pawns: reduce
 // score if both sides have exactly 1 pawn.
 func (e *Evaluation) lastPawnLeft() int {
@@ -237,6 +451,31 @@ func (e *Evaluation) lastPawnLeft() int {
 	return ExistingScore
 }
 
+// Bishop vs. knight: long range bishop is better with pawns on both wings while
+// the knight holds its own when all the pawns are on one wing.
+func (e *Evaluation) bishopVsKnight() int {
+	p := e.position
+	pawns := p.outposts[Pawn] | p.outposts[BlackPawn]
+
+	left, right := 8, -1
+	for col := A1A8; col <= H1H8; col++ {
+		if (pawns & maskFile[col]).any() {
+			left, right = min(left, col), max(right, col)
+		}
+	}
+
+	if spread := right - left; spread > 3 {
+		bonus := bishopSpread.endgame * (spread - 3)
+		if p.outposts[Bishop].any() {
+			e.score.endgame += bonus
+		} else {
+			e.score.endgame -= bonus
+		}
+	}
+
+	return ExistingScore
+}
+
 // One side has 0 pawn and the other side has 0 or more pawns.
 func (e *Evaluation) noPawnsLeft() int {
 	color := e.strongerSide()
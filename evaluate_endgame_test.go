@@ -239,7 +239,7 @@ func TestEndgame380(t *testing.T) {
 // Don't drop a score when a side has extra piece.
 func TestEndgame400(t *testing.T) {
 	score := NewGame(`Ke1,Bf1,Nc3,Nf3,f2,g3,h4`, `Ke8,Bf8,Nf6,f7,g6,h5`).start().Evaluate()
-	expect.Eq(t, score, 444) // Extra Nc3.
+	expect.Eq(t, score, 445) // Extra Nc3.
 
 	score = NewGame(`Ke1,Bf1,Nf3,f2,g3,h4`, `Ke8,Ra8,Bf8,Nf6,f7,g6,h5`).start().Evaluate()
 	expect.Eq(t, score, -709) // Extra Ra8 for black.
@@ -248,10 +248,10 @@ func TestEndgame400(t *testing.T) {
 // Don't drop a score when a side has more that 2 extra pawns.
 func TestEndgame410(t *testing.T) {
 	score := NewGame(`Ke1,Bf1,Nf3,a2,b2,f2,g3,h4`, `Ke8,Bf8,Nf6,f7,g6,h5`).start().Evaluate()
-	expect.Eq(t, score, 95) // Extra a2,b2 pawns, drop the score (266 -> 95).
+	expect.Eq(t, score, 97) // Extra a2,b2 pawns, drop the score (266 -> 97).
 
 	score = NewGame(`Ke1,Bf1,Nf3,f2,g3,h4`, `Ke8,Bf8,Nf6,a7,b7,c7,f7,g6,h5`).start().Evaluate()
-	expect.Eq(t, score, -373) // Extra a7,b7,c7 for black, don't drop the score.
+	expect.Eq(t, score, -387) // Extra a7,b7,c7 for black, don't drop the score.
 }
 
 // Draw if single passer and a king blocks it on safe color square.
@@ -260,7 +260,7 @@ func TestEndgame420(t *testing.T) {
 	expect.Eq(t, score, 0)
 
 	score = NewGame(`Kf6,Be2,e7`, `Ke8,Bf2`).start().Evaluate() // King on e8 is not blocking (Bh5+).
-	expect.Eq(t, score, 206)
+	expect.Eq(t, score, 282)
 }
 
 // Draw if single passer and a bishop controls a square in front of it.
@@ -269,5 +269,146 @@ func TestEndgame430(t *testing.T) {
 	expect.Eq(t, score, 0)
 
 	score = NewGame(`Kd6,Bb8`, `M,Ke8,Bc8,h3`).start().Evaluate() // Bb8 is blocked by Kd6 and doesn't control h2.
-	expect.Eq(t, score, 295)
+	expect.Eq(t, score, 323)
+}
+
+// Draw if rook pawn and wrong colored bishop with defending king in the corner.
+func TestEndgame440(t *testing.T) {
+	score := NewGame(`Kf5,Bc4,h5`, `Kh8`).start().Evaluate() // Light bishop, dark H8.
+	expect.Eq(t, score, 0)
+
+	score = NewGame(`Kf5,Bc4,h5`, `Kg7`).start().Evaluate()
+	expect.Eq(t, score, 0)
+
+	score = NewGame(`Kb1`, `M,Kd4,Bd5,a3`).start().Evaluate() // Light bishop, dark A1.
+	expect.Eq(t, score, 0)
+
+	score = NewGame(`Kf5,Bd4,h5`, `Kh8`).start().Evaluate() // Dark bishop controls H8.
+	expect.True(t, score > 0)
+
+	score = NewGame(`Kf5,Bc4,h5`, `Ke7`).start().Evaluate() // King is away from the corner.
+	expect.True(t, score > 0)
+}
+
+// Stalemate in queen vs. bare king is a draw, and the search avoids it.
+func TestEndgame450(t *testing.T) {
+	score := NewGame(`Kf7,Qg6`, `M,Kh8`).start().Evaluate() // Stalemate.
+	expect.Eq(t, score, 0)
+
+	score = NewGame(`Kf7,Qg5`, `M,Kh8`).start().Evaluate()
+	expect.True(t, score < 0) // Black to move is losing.
+
+	p := NewGame(`Kf7,Qa6`, `Kh8`).start() // Qa6-g6 stalemates.
+	move := p.solve(3)
+	expect.Eq(t, move, `Qa6-h6`)
+}
+
+// Bare king is better off in the center than in the corner.
+func TestEndgame460(t *testing.T) {
+	center := NewGame(`Ka1,Rb1`, `Kd5`).start().Evaluate()
+	corner := NewGame(`Ka1,Rb1`, `Kh8`).start().Evaluate()
+	expect.True(t, corner > center)
+}
+
+// Bishop vs. knight: pawns on both wings favor the bishop.
+func TestEndgame470(t *testing.T) {
+	wide := NewGame(`Kg1,Bd3,a2,g2,h2`, `Kg8,Nd7,a7,g7,h7`).start().Evaluate()
+	narrow := NewGame(`Kg1,Bd3,f2,g2,h2`, `Kg8,Nd7,f7,g7,h7`).start().Evaluate()
+	expect.True(t, wide > narrow)
+
+	wide = NewGame(`Kg1,Nd2,a2,g2,h2`, `Kg8,Bd6,a7,g7,h7`).start().Evaluate()
+	narrow = NewGame(`Kg1,Nd2,f2,g2,h2`, `Kg8,Bd6,f7,g7,h7`).start().Evaluate()
+	expect.True(t, wide < narrow)
+}
+
+// Rook and pawn vs. rook: rook cutting off enemy king (Lucena position).
+func TestEndgame480(t *testing.T) {
+	cut := NewGame(`Kb8,Rc1,b7`, `Ke7,Ra2`).start().Evaluate()
+	free := NewGame(`Kb8,Rf1,b7`, `Ke7,Ra2`).start().Evaluate()
+	expect.True(t, cut > free)
+
+	cut = NewGame(`Kh4,Rh8`, `M,Kb1,Rc5,b3`).start().Evaluate() // Black to move.
+	free = NewGame(`Kh4,Rh8`, `M,Kb1,Ra5,b3`).start().Evaluate()
+	expect.True(t, cut > free)
+}
+
+// King and pawns vs. king and pawns: holding the opposition matters when
+// the side to move has no spare pawn moves.
+func TestEndgame490(t *testing.T) {
+	score := NewGame(`Kd3,a4,h4`, `M,Kd5,a6,h5`).start().Evaluate()
+	expect.True(t, score > 0)
+
+	score = NewGame(`Kd3,a4,h4`, `M,Kd5,a5,h5`).start().Evaluate()
+	expect.True(t, score < 0)
+}
+
+// Equal heavy pieces and locked pawn wall.
+func TestEndgame500(t *testing.T) {
+	white := `Kd2,Qe2,Rh1,Rh2,a3,b4,c3,d4,e3,f4,g3,h4`
+	black := `Kg8,Qa7,Ra8,Rb8,a4,b5,c4,d5,e4,f5,g4,h5`
+	locked := NewGame(white, black).start().Evaluate()
+	expect.True(t, abs(locked) < onePawn / 4)
+
+	// The wall doesn't have to be symmetric: white c-pawn is one rank ahead
+	// of its neighbors but there are still no pawn captures.
+	white = `Kd2,Qe2,Rh1,Rh2,a3,b4,c5,d4,e3,f4,g3,h4`
+	black = `Kg8,Qa7,Ra8,Rb8,a4,b5,c6,d5,e4,f5,g4,h5`
+	locked = NewGame(white, black).start().Evaluate()
+	expect.True(t, abs(locked) < onePawn / 4)
+
+	// No pawns on the h-file opens the wall for white rooks.
+	white = `Kd2,Qe2,Rh1,Rh2,a3,b4,c3,d4,e3,f4,g3`
+	black = `Kg8,Qa7,Ra8,Rb8,a4,b5,c4,d5,e4,f5,g4`
+	open := NewGame(white, black).start().Evaluate()
+	expect.True(t, open > onePawn / 2)
+}
+
+// Rook and pawn vs. rook: Lucena win and Philidor and back rank draws.
+func TestEndgame510(t *testing.T) {
+	lucena := NewGame(`Kd8,Re1,d7`, `Kf7,Ra2`).start().Evaluate()
+	expect.True(t, lucena > onePawn * 2)
+	lucena = NewGame(`Kh1,Ra3`, `M,Kd1,Re8,d2`).start().Evaluate() // Black to move.
+	expect.True(t, lucena > onePawn * 2)
+
+	philidor := NewGame(`Kd5,Ra7,e5`, `Ke8,Rh6`).start().Evaluate()
+	expect.Eq(t, philidor, 0)
+	philidor = NewGame(`Kd5,Ra7,e5`, `M,Ke8,Rh6`).start().Evaluate()
+	expect.Eq(t, philidor, 0)
+
+	backRank := NewGame(`Kf6,Ra7,g6`, `Kg8,Rb8`).start().Evaluate()
+	expect.Eq(t, backRank, 0)
+	central := NewGame(`Kf6,Ra7,e6`, `Ke8,Rb8`).start().Evaluate() // Back rank defense loses.
+	expect.True(t, central > onePawn)
+}
+
+// King and rook vs. king: the engine keeps shrinking the bare king's space
+// without repeating positions until it delivers the mate.
+func TestEndgame520(t *testing.T) {
+	for _, setup := range [][2]string{{`Kd3,Rh1`, `Ke5`}, {`Ka1,Rb1`, `Ke4`}} {
+		game := NewGame(setup[0], setup[1])
+		p := game.start()
+		seen, space, smallest, mate := map[uint64]bool{}, 64, 64, false
+
+		for moves := 1; moves <= 25 && !mate; moves++ {
+			move, _ := think(4, 16, false)
+			p = p.makeMove(move)
+			expect.False(t, seen[p.id])
+			seen[p.id] = true
+			smallest = min(smallest, p.kingSpace(Black))
+
+			if mate = !NewGen(p, MaxPly).generateAllMoves().anyValid(); mate {
+				expect.True(t, p.isInCheck(Black))
+			} else {
+				move, _ := think(4, 16, false)
+			p = p.makeMove(move)
+			}
+
+			// The smallest king space within every five moves goes down.
+			if moves % 5 == 0 || mate {
+				expect.True(t, smallest < space)
+				space, smallest = smallest, 64
+			}
+		}
+		expect.True(t, mate)
+	}
 }
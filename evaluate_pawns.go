@@ -8,21 +8,49 @@
 
 package donna
 
+import `unsafe`
+
 type PawnEntry struct {
 	id       uint64 	// Pawn hash key.
 	score    Score 		// Static score for the given pawn structure.
 	king     [2]int 	// King square for both sides.
 	cover    [2]Score 	// King cover penalties for both sides.
+	storm    [2]int16 	// Midgame pawn storm bonuses for both sides.
+	castles  uint8 		// Castle rights king cover was calculated for.
 	passers  [2]Bitmask 	// Passed pawn bitmasks for both sides.
+	weak     [2]Bitmask 	// Isolated and backward pawn bitmasks for both sides.
+	files    [8]uint8 	// Pawns by file: bit 0 is set for white pawns, bit 1 for black.
+	fixedLight [2]uint8 	// Number of pawns fixed on light squares for both sides.
+	fixedDark  [2]uint8 	// Number of pawns fixed on dark squares for both sides.
 }
 
-type PawnCache [8192*2]PawnEntry
+type PawnCache []PawnEntry
+
+// Default pawn cache size in megabytes.
+const defaultPawnCache = 2
+
+// Allocates new zero-initialized pawn cache. The number of entries gets rounded
+// down to the power of two so that cache index could be calculated by masking
+// the pawn hash key.
+func (game *Game) resizePawnCache(megaBytes int) *Game {
+	if megaBytes <= 0 {
+		megaBytes = defaultPawnCache
+	}
+
+	entries, size := megaBytes * 1024 * 1024 / int(unsafe.Sizeof(PawnEntry{})), 1
+	for size * 2 <= entries { // Round down to the power of 2.
+		size *= 2
+	}
+	game.pawnCache = make(PawnCache, size)
+
+	return game
+}
 
 func (e *Evaluation) analyzePawns() {
 	key := e.position.pawnId
 
 	// Since pawn hash is fairly small we can use much faster 32-bit index.
-	index := uint32(key) % uint32(len(game.pawnCache))
+	index := uint32(key) & uint32(len(game.pawnCache) - 1)
 	e.pawns = &game.pawnCache[index]
 
 	// Bypass pawns cache if evaluation tracing is enabled.
@@ -48,15 +76,128 @@ func (e *Evaluation) analyzePassers() {
 
 	if engine.trace {
 		defer func() {
+			var tarrasch Total
+			for bm := e.pawns.passers[White]; bm.any(); bm = bm.pop() {
+				tarrasch.white.add(e.rookBehindPasser(White, bm.first()))
+			}
+			for bm := e.pawns.passers[Black]; bm.any(); bm = bm.pop() {
+				tarrasch.black.add(e.rookBehindPasser(Black, bm.first()))
+			}
 			e.checkpoint(`Passers`, Total{white, black})
+			e.checkpoint(`-Tarrasch`, tarrasch)
 		}()
 	}
 
 	white, black = e.pawnPassers(White), e.pawnPassers(Black)
+
+	// In pure pawn endgame award extra bonus to the side winning pawn race.
+	switch e.pawnRace() {
+	case White:
+		white.endgame += unstoppablePawn
+	case Black:
+		black.endgame += unstoppablePawn
+	}
+
 	score.add(white).sub(black).apply(weightPassedPawns)
 	e.score.add(score)
 }
 
+// Calculates pawn race in pure pawn endgame when both sides have unstoppable
+// passers. Returns the side that queens first and wins the race, or -1 if
+// there is no race or the race is likely a draw.
+func (e *Evaluation) pawnRace() int {
+	p := e.position
+	if (p.outposts[White] ^ p.outposts[Pawn] ^ p.outposts[King]).any() ||
+	   (p.outposts[Black] ^ p.outposts[BlackPawn] ^ p.outposts[BlackKing]).any() {
+		return -1
+	}
+
+	// Find the fastest passer that can't be caught by enemy's king for
+	// both sides, and count plies it takes to promote it.
+	var plies, passers, queens [2]int
+	for color := White; color <= Black; color++ {
+		plies[color] = MaxPly
+		for bm := e.pawns.passers[color]; bm.any(); bm = bm.pop() {
+			passer := bm.first()
+			if (p.outposts[color] & maskInFront[color][passer]).any() {
+				continue
+			}
+
+			mask := maskSquareEx[color][passer]
+			if p.color == color {
+				mask = maskSquare[color][passer]
+			}
+			if (mask & p.outposts[king(color^1)]).any() {
+				continue
+			}
+
+			moves := A8H8 - rank(color, passer)
+			if rank(color, passer) == A2H2 {
+				moves-- // Double step.
+			}
+			if n := moves * 2 - let(p.color == color, 1, 0); n < plies[color] {
+				plies[color], passers[color] = n, passer
+				queens[color] = square(let(color == White, A8H8, A1H1), col(passer))
+			}
+		}
+	}
+
+	if plies[White] == MaxPly || plies[Black] == MaxPly {
+		return -1
+	}
+
+	// The side that queens first wins if the opponent needs more than one
+	// move to queen in reply. Otherwise the new queen must either check the
+	// enemy king or take enemy's queen as soon as it gets promoted.
+	our := let(plies[White] < plies[Black], White, Black)
+	their := our^1
+	if plies[their] - plies[our] > 1 {
+		return our
+	}
+
+	board := p.board ^ bit[passers[our]] | bit[queens[our]] // Board after the promotion.
+	attacks := p.queenMovesAt(queens[our], board)
+	if attacks.on(p.king[their]) || (attacks.on(queens[their]) && distance[p.king[their]][queens[their]] > 1) {
+		return our
+	}
+
+	return -1
+}
+
+func (e *Evaluation) analyzeLevers() {
+	var white, black Score
+
+	if engine.trace {
+		defer func() {
+			e.checkpoint(`Levers`, Total{white, black})
+		}()
+	}
+
+	white, black = e.pawnLevers(White), e.pawnLevers(Black)
+	e.score.add(white).sub(black)
+}
+
+// Calculates midgame bonus for pawn levers, i.e. enemy pawns that our pawns
+// can capture to change the structure. Levers in the center and next to the
+// enemy's king are worth more.
+func (e *Evaluation) pawnLevers(our int) (score Score) {
+	p, their := e.position, our^1
+
+	levers := e.attacks[pawn(our)] & p.outposts[pawn(their)]
+	for bm := levers; bm.any(); bm = bm.pop() {
+		square := bm.first()
+		score.add(pawnLever)
+		if col := col(square); col >= C1C8 && col <= F1F8 {
+			score.add(pawnLever)
+		}
+		if distance[square][p.king[their]] <= 2 {
+			score.add(pawnLever)
+		}
+	}
+
+	return
+}
+
 // Calculates extra bonus and penalty based on pawn structure. Specifically,
 // a bonus is awarded for passed pawns, and penalty applied for isolated and
 // doubled pawns.
@@ -65,6 +206,16 @@ func (e *Evaluation) pawnStructure(our int) (score Score) {
 	ourPawns := e.position.outposts[pawn(our)]
 	theirPawns := e.position.outposts[pawn(their)]
 	e.pawns.passers[our] = 0
+	weak := Bitmask(0) // Isolated and backward pawns.
+
+	// Note which files have our pawns so that piece evaluation could spot
+	// open and semi-open files without looking at the pawns again.
+	for column := 0; column < 8; column++ {
+		e.pawns.files[column] &= ^uint8(1 << uint(our))
+		if (ourPawns & maskFile[column]).any() {
+			e.pawns.files[column] |= 1 << uint(our)
+		}
+	}
 
 	for bm := ourPawns; bm.any(); bm = bm.pop() {
 		square := bm.first()
@@ -87,6 +238,7 @@ func (e *Evaluation) pawnStructure(our int) (score Score) {
 		// on adjacent files. The penalty goes up if isolated pawn is
 		// exposed on semi-open file.
 		if isolated {
+			weak |= bit[square]
 			if !exposed {
 				score.sub(penaltyIsolatedPawn[col])
 			} else {
@@ -117,6 +269,7 @@ func (e *Evaluation) pawnStructure(our int) (score Score) {
 					enemy := pawnAttacks[our][square].up(our)
 					if ((enemy | enemy.up(our)) & theirPawns).any() {
 						backward = true
+						weak |= bit[square]
 						if !exposed {
 							score.sub(penaltyBackwardPawn[col])
 						} else {
@@ -127,19 +280,125 @@ func (e *Evaluation) pawnStructure(our int) (score Score) {
 			}
 		}
 
-		// Bonus if the pawn has good chance to become a passed pawn.
+		// Bonus if the pawn has good chance to become a passed pawn: either
+		// by capturing enemy pawn or by outnumbering the enemy pawns.
 		if exposed && !isolated && !passed && !backward {
-			his := maskPassed[their][square + up[our]] & maskIsolated[col] & ourPawns
-			her := maskPassed[our][square] & maskIsolated[col] & theirPawns
-			if his.count() >= her.count() {
-				score.add(bonusSemiPassedPawn[rank(our, square)])
+			if e.isCandidatePasser(our, square) {
+				score.add(bonusCandidatePasser[rank(our, square)])
+			} else {
+				his := maskPassed[their][square + up[our]] & maskIsolated[col] & ourPawns
+				her := maskPassed[our][square] & maskIsolated[col] & theirPawns
+				if his.count() >= her.count() {
+					score.add(bonusSemiPassedPawn[rank(our, square)])
+				}
 			}
 		}
 	}
 
+	e.pawns.weak[our] = weak
+
+	// Count our pawns blocked by enemy pawns on light and dark squares so
+	// that bishop evaluation could spot bad bishops.
+	fixed := ourPawns & theirPawns.up(their)
+	e.pawns.fixedLight[our] = uint8((fixed & ^maskDark).count())
+	e.pawns.fixedDark[our] = uint8((fixed & maskDark).count())
+
+	// Principle of two weaknesses: weak pawns far apart on different wings
+	// stretch defending pieces too thin.
+	if weak.count() > 1 {
+		left, right := col(weak.first()), col(weak.first())
+		for bm := weak; bm.any(); bm = bm.pop() {
+			left, right = min(left, col(bm.first())), max(right, col(bm.first()))
+		}
+		if right - left >= 4 {
+			score.sub(twoWeaknesses)
+		}
+	}
+
+	// Minority attack: bonus if our queenside minority is advancing to
+	// create a weakness, and penalty if we're about to be left with one.
+	if stage := e.minorityStage(our); stage > 0 {
+		score.add(minorityAttack.times(stage))
+	}
+	if stage := e.minorityStage(their); stage > 0 {
+		score.sub(minorityWeak.times(stage))
+	}
+
 	return score
 }
 
+// Returns true if the pawn becomes passed after capturing enemy pawn, i.e. the
+// pawn lever opens the way forward. The lever could be already in place, or
+// show up later if we have at least as many pawns as the enemy to support the
+// exchange on adjacent files.
+func (e *Evaluation) isCandidatePasser(our, square int) bool {
+	their, col := our^1, col(square)
+	ourPawns, theirPawns := e.position.outposts[pawn(our)], e.position.outposts[pawn(their)]
+
+	levers := pawnAttacks[our][square] & theirPawns
+	if levers.empty() {
+		his := maskPassed[their][square + up[our]] & maskIsolated[col] & ourPawns
+		her := maskPassed[our][square] & maskIsolated[col] & theirPawns
+		if his.count() >= her.count() {
+			levers = her
+		}
+	}
+
+	for bm := levers; bm.any(); bm = bm.pop() {
+		target := bm.first()
+		if (maskPassed[our][target] & theirPawns & ^bit[target]).empty() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Evaluates pawn structure for the given side without touching shared pawn
+// cache, which makes it safe to call from multiple goroutines. Returns raw
+// unweighted score along with the bitmask of the side's passed pawns.
+func EvaluatePawns(p *Position, color int) (Score, Bitmask) {
+	e := &Evaluation{ position: p, pawns: &PawnEntry{} }
+	score := e.pawnStructure(color)
+
+	return score, e.pawns.passers[color]
+}
+
+// Returns the bitmask of passed pawns for the given side as determined by
+// pawn structure evaluation.
+func (p *Position) PassedPawns(color int) Bitmask {
+	_, passers := EvaluatePawns(p, color)
+
+	return passers
+}
+
+// Detects minority attack, i.e. two queenside pawns facing three enemy ones
+// with our c-file half open. Returns 0 if there is no attack, 1 if our b-pawn
+// has reached the 4th rank, and 2 if it has reached the 5th.
+func (e *Evaluation) minorityStage(our int) int {
+	p, their := e.position, our^1
+	queenside := maskFile[A1A8] | maskFile[B1B8] | maskFile[C1C8]
+	ours, theirs := p.outposts[pawn(our)] & queenside, p.outposts[pawn(their)] & queenside
+
+	if ours.count() != 2 || theirs.count() != 3 || (ours & maskFile[C1C8]).any() || (theirs & maskFile[C1C8]).empty() {
+		return 0
+	}
+
+	attacker := ours & maskFile[B1B8]
+	if attacker.empty() {
+		return 0
+	}
+
+	switch rank := rank(our, attacker.farthest(our)); {
+	case rank >= A5H5:
+		return 2
+	case rank >= A4H4:
+		return 1
+	}
+
+	return 0
+}
+
 func (e *Evaluation) pawnPassers(our int) (score Score) {
 	p, their := e.position, our^1
 
@@ -195,9 +454,33 @@ func (e *Evaluation) pawnPassers(our int) (score Score) {
 				if boost > 0 {
 					bonus.adjust(extra * boost)
 				}
+			} else if p.outposts[king(their)].on(nextSquare) {
+				// The passer is stopped by enemy king rather than a piece. The
+				// king gets tied down to the blockade and can't help elsewhere.
+				bonus.endgame += extra * 6
+			}
+		}
+
+		// Bonus for connected passers, i.e. passers on adjacent files and
+		// same or neighboring ranks.
+		row, col := coordinate(square)
+		if connected := maskIsolated[col] & (maskRank[row] | maskRank[row].up(our) | maskRank[row].up(their)) & e.pawns.passers[our]; connected.any() {
+			bonus.add(bonusConnectedPassers[rank])
+
+			// Passers that are either side by side or defend each other
+			// advance in tandem and are very hard to stop.
+			if (connected & ^maskRank[row].up(our)).any() {
+				bonus.add(bonusPassedPawnDuo[rank])
 			}
 		}
 
+		// Rooks belong behind passed pawns: ours supports the advance, and
+		// enemy's one keeps the pawn under attack.
+		bonus.add(e.rookBehindPasser(our, square))
+
+		// Reward the passer that needs fewer tempos to reach the 8th rank.
+		bonus.endgame += bonusTempoPassedPawn[e.passerTempo(our, square)]
+
 		// Before chasing the unstoppable make sure own pieces are not blocking the passer.
 		if chase && (p.outposts[our] & maskInFront[our][square]).empty() {
 			// Pick square rule bitmask for the pawn. If defending king has the right
@@ -219,3 +502,31 @@ func (e *Evaluation) pawnPassers(our int) (score Score) {
 	return score
 }
 
+// Returns endgame bonus for our rook standing behind the passer on the same
+// file, or penalty if enemy rook is there. Both scale with the passer's rank.
+func (e *Evaluation) rookBehindPasser(our, square int) (score Score) {
+	p, their := e.position, our^1
+
+	// The first piece behind the passer along the file.
+	behind := p.rookMoves(square) & maskInFront[their][square]
+	if (behind & p.outposts[rook(our)]).any() {
+		score.add(rookBehindPasser.times(rank(our, square)))
+	} else if (behind & p.outposts[rook(their)]).any() {
+		score.sub(rookBehindEnemy.times(rank(our, square)))
+	}
+
+	return score
+}
+
+// Returns the number of tempos the passer needs to promote counting two extra
+// tempos for every piece that blocks its path. The result is capped at 7.
+func (e *Evaluation) passerTempo(our, square int) int {
+	tempo := A8H8 - rank(our, square)
+	if rank(our, square) == A2H2 {
+		tempo-- // Double step.
+	}
+	tempo += 2 * (e.position.board & maskInFront[our][square]).count()
+
+	return min(tempo, 7)
+}
+
@@ -21,14 +21,72 @@ func TestEvaluatePawns110(t *testing.T) {
 	game := NewGame(`Ke1,h2,h3`, `Ke8,a7,h7`)
 	score := game.start().Evaluate()
 
-	expect.Eq(t, score, -10)
+	expect.Eq(t, score, 9)
 }
 
 func TestEvaluatePawns120(t *testing.T) {
 	game := NewGame(`Ke1,f4,f5`, `Ke8,f7,h7`)
 	score := game.start().Evaluate()
 
-	expect.Eq(t, score, -31)
+	expect.Eq(t, score, -37)
+}
+
+// Pawn structure weight tapers by game phase: doubled pawns hurt more in pawn
+// endgame than on the full board.
+func TestEvaluatePawns125(t *testing.T) {
+	whitePawns, blackPawns := `a2,b2,c2,c3,e2,f2,g2,h2`, `a7,b7,c7,d7,e7,f7,g7,h7`
+
+	p := NewGame(`Ke1,Qd1,Ra1,Rh1,Nb1,Ng1,Bc1,Bf1,` + whitePawns, `Ke8,Qd8,Ra8,Rh8,Nb8,Ng8,Bc8,Bf8,` + blackPawns).start()
+	midgame := eval.init(p).RunTerm(`pawns`).blended(eval.material.phase)
+
+	p = NewGame(`Ke1,` + whitePawns, `Ke8,` + blackPawns).start()
+	endgame := eval.init(p).RunTerm(`pawns`).blended(eval.material.phase)
+
+	expect.True(t, midgame < 0)
+	expect.True(t, endgame < midgame)
+}
+
+// Minority attack in Carlsbad structure.
+func TestEvaluatePawns127(t *testing.T) {
+	black := `Kg8,Rc8,Nf6,a7,b7,c6,d5,f7,g7,h7`
+
+	p := NewGame(`Kg1,Rb1,Nf3,a2,b5,d4,e3,f2,g2,h2`, black).start()
+	eval.init(p)
+	expect.Eq(t, eval.minorityStage(White), 2)
+	expect.Eq(t, eval.minorityStage(Black), 0)
+	advanced := eval.init(p).RunTerm(`pawns`)
+
+	p = NewGame(`Kg1,Rb1,Nf3,a2,b4,d4,e3,f2,g2,h2`, black).start()
+	expect.Eq(t, eval.init(p).minorityStage(White), 1)
+
+	p = NewGame(`Kg1,Rb1,Nf3,a2,b4,c2,d4,f2,g2,h2`, black).start() // No half open c-file.
+	expect.Eq(t, eval.init(p).minorityStage(White), 0)
+
+	_, metrics := NewGame(`Kg1,Rb1,Nf3,a2,b5,d4,e3,f2,g2,h2`, black).start().EvaluateWithTrace()
+	expect.True(t, metrics[`Pawns`].(Total).black.midgame < 0)
+	expect.True(t, advanced.midgame > 0)
+}
+
+// Two weaknesses on different wings are worse than two weaknesses close by.
+func TestEvaluatePawns128(t *testing.T) {
+	p := NewGame(`Kg1,Rb1`, `Kg8,Rb8,a6,e6,g7,h7`).start()
+	apart := eval.init(p).RunTerm(`pawns`)
+
+	p = NewGame(`Kg1,Rb1`, `Kg8,Rb8,a6,c6,g7,h7`).start()
+	close := eval.init(p).RunTerm(`pawns`)
+
+	expect.True(t, apart.endgame > close.endgame) // White's point of view.
+}
+
+func TestEvaluatePawns130(t *testing.T) { // Doubled rook pawns vs doubled center pawns.
+	_, metrics := NewGame(`Ke1,a2,a3,b2,d2,e2`, `Ke8,a7,b7,d7,e7`).start().EvaluateWithTrace()
+	rook := metrics[`Pawns`].(Total).white
+
+	_, metrics = NewGame(`Ke1,a2,b2,d2,d3,e2`, `Ke8,a7,b7,d7,e7`).start().EvaluateWithTrace()
+	center := metrics[`Pawns`].(Total).white
+
+	expect.True(t, rook.midgame > center.midgame)
+	expect.True(t, rook.endgame > center.endgame)
 }
 
 // Passed pawns.
@@ -50,7 +108,7 @@ func TestEvaluatePawns220(t *testing.T) {
 	game := NewGame(`Ke1,a4,e4`, `Ke8,a5,d6`) // Can't pass.
 	score := game.start().Evaluate()
 
-	expect.Eq(t, score, -2)
+	expect.Eq(t, score, -20)
 }
 
 func TestEvaluatePawns230(t *testing.T) {
@@ -64,14 +122,14 @@ func TestEvaluatePawns240(t *testing.T) {
 	game := NewGame(`Kd1,e5`, `Ke8,d5`) // Both passing but white is closer.
 	score := game.start().Evaluate()    // No KPKP since the pawn crossed A4H4.
 
-	expect.Eq(t, score, 32)
+	expect.Eq(t, score, 35)
 }
 
 func TestEvaluatePawns250(t *testing.T) {
 	game := NewGame(`Ke1,a5,b2`, `Kd8,g7,h7`) // Both passing but white is much closer.
 	score := game.start().Evaluate()
 
-	expect.Eq(t, score, 102)
+	expect.Eq(t, score, 116)
 }
 
 // Isolated pawns.
@@ -86,7 +144,97 @@ func TestEvaluatePawns310(t *testing.T) {
 	game := NewGame(`Ke1,a2,c2,e2`, `Ke8,a7,b7,c7`) // White pawns are isolated.
 	score := game.start().Evaluate()
 
-	expect.Eq(t, score, -28)
+	expect.Eq(t, score, -50)
+}
+
+// Pawn levers.
+func TestEvaluatePawns350(t *testing.T) { // Central d4xe5 break vs locked center.
+	_, metrics := NewGame(`Ke1,c3,d4,e4`, `Ke8,c6,d6,e5`).start().EvaluateWithTrace()
+	lever := metrics[`Levers`].(Total).white
+
+	_, metrics = NewGame(`Ke1,c3,d4,e5`, `Ke8,c6,d5,e6`).start().EvaluateWithTrace()
+	locked := metrics[`Levers`].(Total).white
+
+	expect.Eq(t, lever, pawnLever.times(2))
+	expect.Eq(t, locked, Score{0, 0})
+}
+
+// Pawn race.
+func TestEvaluatePawns360(t *testing.T) { // White queens first with check.
+	p := NewGame(`Ka1,b5`, `Kh8,g4`).start()
+	eval.init(p).RunTerm(`passers`)
+	expect.Eq(t, eval.pawnRace(), White)
+	expect.True(t, p.Evaluate() > onePawn * 5)
+}
+
+func TestEvaluatePawns370(t *testing.T) { // Black queens first with check.
+	p := NewGame(`Ka1,b5`, `M,Kh8,g4`).start()
+	eval.init(p).RunTerm(`passers`)
+	expect.Eq(t, eval.pawnRace(), Black)
+	expect.True(t, p.Evaluate() > onePawn * 5) // Black to move.
+}
+
+func TestEvaluatePawns380(t *testing.T) { // Both sides queen, no check.
+	p := NewGame(`Kb3,b5`, `Kh7,g4`).start()
+	eval.init(p).RunTerm(`passers`)
+	expect.Eq(t, eval.pawnRace(), -1)
+}
+
+func TestEvaluatePawns390(t *testing.T) { // White is two moves ahead.
+	p := NewGame(`Kb3,b6`, `Kh7,g4`).start()
+	eval.init(p).RunTerm(`passers`)
+	expect.Eq(t, eval.pawnRace(), White)
+}
+
+// Tempo to promote.
+func TestEvaluatePawns395(t *testing.T) {
+	p := NewGame(`Kg1,Nb1,d5`, `Kg8`).start()
+	clear := eval.init(p).RunTerm(`passers`)
+	expect.Eq(t, eval.passerTempo(White, D5), 3)
+
+	p = NewGame(`Kg1,Nd7,d5`, `Kg8`).start()
+	blocked := eval.init(p).RunTerm(`passers`)
+	expect.Eq(t, eval.passerTempo(White, D5), 5)
+
+	expect.True(t, clear.endgame > blocked.endgame)
+}
+
+// Connected passers on adjacent files and neighboring ranks: both d5 and e4
+// get the bonus reported under Passers checkpoint.
+func TestEvaluatePawns396(t *testing.T) {
+	p := NewGame(`Kb1,d5,e4,h2`, `Kg8,Rh8,h7`).start()
+	_, metrics := p.EvaluateWithTrace()
+	connected := metrics[`Passers`].(Total).white
+
+	table := bonusConnectedPassers; defer func() { bonusConnectedPassers = table }()
+	bonusConnectedPassers = [8]Score{}
+	_, metrics = p.EvaluateWithTrace()
+	without := metrics[`Passers`].(Total).white
+
+	expect.Eq(t, connected.midgame - without.midgame, table[4].midgame + table[3].midgame)
+	expect.Eq(t, connected.endgame - without.endgame, table[4].endgame + table[3].endgame)
+}
+
+// Passed pawn duo.
+func TestEvaluatePawns398(t *testing.T) {
+	p := NewGame(`Kb1,d5,e6`, `Kd8`).start()
+	duo := eval.init(p).RunTerm(`passers`)
+
+	p = NewGame(`Kb1,b5,e6`, `Kd8`).start()
+	apart := eval.init(p).RunTerm(`passers`)
+
+	expect.True(t, duo.endgame > apart.endgame * 3 / 2)
+}
+
+// Passer blockaded by the king vs. the knight.
+func TestEvaluatePawns399(t *testing.T) {
+	p := NewGame(`Kg1,e6,h2`, `Ke7,Na8,h7`).start()
+	king := eval.init(p).RunTerm(`passers`)
+
+	p = NewGame(`Kg1,e6,h2`, `Kd8,Ne7,h7`).start()
+	knight := eval.init(p).RunTerm(`passers`)
+
+	expect.True(t, king.endgame > knight.endgame)
 }
 
 // Rooks.
@@ -94,21 +242,21 @@ func TestEvaluatePawns400(t *testing.T) {
 	game := NewGame(`Ke1,Ra7`, `Ke8,Rh3`) // White on 7th.
 	score := game.start().Evaluate()
 
-	expect.Eq(t, score, 5)
+	expect.Eq(t, score, 4)
 }
 
 func TestEvaluatePawns410(t *testing.T) {
 	game := NewGame(`Ke1,Rb1,Ng2,a2`, `Ke8,Rh8,Nb7,h7`) // White on open file.
 	score := game.start().Evaluate()
 
-	expect.Eq(t, score, 81)
+	expect.Eq(t, score, 77)
 }
 
 func TestEvaluatePawns420(t *testing.T) {
 	game := NewGame(`Ke1,Rb1,a2,g2`, `Ke8,Rh8,h7,b7`) // White on semi-open file.
 	score := game.start().Evaluate()
 
-	expect.Eq(t, score, 108)
+	expect.Eq(t, score, 127)
 }
 
 // King shield.
@@ -136,7 +284,7 @@ func TestEvaluatePawns520(t *testing.T) {
 	game := NewGame(`Kg1,f2,g2,h2,Qa3,Na4`, `Kg8,a7,f7,g7,Qa6,Na5`) // h2,g2,h2 vs A7,f7,g7
 	score := game.start().Evaluate()
 
-	expect.Eq(t, score, 45)
+	expect.Eq(t, score, 47)
 }
 
 func TestEvaluatePawns530(t *testing.T) {
@@ -157,7 +305,7 @@ func TestEvaluatePawns550(t *testing.T) {
 	game := NewGame(`Kb1,b2,c2,h2,Qh3,Nh4`, `Kb8,a7,b7,c7,Qh6,Nh5`) // b2,c2,H2 vs a7,b7,c7
 	score := game.start().Evaluate()
 
-	expect.Eq(t, score, -25)
+	expect.Eq(t, score, -27)
 }
 
 func TestEvaluatePawns560(t *testing.T) {
@@ -179,26 +327,128 @@ func TestEvaluatePawns600(t *testing.T) {
 	game := NewGame(`Kd4,f2,g2,h2`, `Kg8,g7,h7,a3`) // Kd4-c3 stops A3 pawn.
 	score := game.start().Evaluate()
 
-	expect.Eq(t, score, -90)
+	expect.Eq(t, score, -117)
 }
 
 func TestEvaluatePawns610(t *testing.T) {
 	game := NewGame(`Kd4,f2,g2,h2`, `M99,Kg8,g7,h7,a3`) // a3-a2 makes the pawn unstoppable.
 	score := game.start().Evaluate()
 
-	expect.Eq(t, score, 1190)
+	expect.Eq(t, score, 1217)
 }
 
 func TestEvaluatePawns620(t *testing.T) {
 	game := NewGame(`Ka1,b4,g2`, `Kg8,g7,h7`) // b4-b5 is unstoppable.
 	score := game.start().Evaluate()
 
-	expect.Eq(t, score, 1063)
+	expect.Eq(t, score, 1052)
 }
 
 func TestEvaluatePawns630(t *testing.T) {
 	game := NewGame(`Ka1,b4,h2`, `M99,Kg8,g7,h7`) // Kg8-f8 stops B4 pawn.
 	score := game.start().Evaluate()
 
-	expect.Eq(t, score, 31)
+	expect.Eq(t, score, 42)
+}
+
+// Pawn cache size affects hit rate only, not the evaluation.
+func TestEvaluatePawns700(t *testing.T) {
+	defer func() { engine.pawnCacheSize = 0 }()
+	fen := `r1bq1rk1/pp2bppp/2n1pn2/3p4/2PP4/2N1PN2/P4PPP/R1BQKB1R w KQ - 0 8`
+
+	engine.pawnCacheSize = 1
+	game := NewGame(fen)
+	small := game.start().Evaluate()
+	expect.Eq(t, len(game.pawnCache), 8192)
+	expect.Eq(t, game.start().Evaluate(), small)
+
+	engine.pawnCacheSize = 16
+	game = NewGame(fen)
+	large := game.start().Evaluate()
+	expect.Eq(t, len(game.pawnCache), 8192 * 16)
+	expect.Eq(t, large, small)
+}
+
+// Standalone pawn structure evaluation.
+func TestEvaluatePawns710(t *testing.T) { // Isolated passers.
+	p := NewGame(`Ke1,a2,c2`, `Ke8,h7`).start()
+	score, passers := EvaluatePawns(p, White)
+	expected := Score{}
+	expected.sub(penaltyWeakIsolatedPawn[0]).sub(penaltyWeakIsolatedPawn[2])
+	expect.Eq(t, score, expected)
+	expect.Eq(t, passers, bit[A2] | bit[C2])
+}
+
+func TestEvaluatePawns720(t *testing.T) { // Doubled isolated pawns.
+	p := NewGame(`Ke1,e2,e3`, `Ke8,d7,f7`).start()
+	score, passers := EvaluatePawns(p, White)
+	expected := Score{}
+	expected.sub(penaltyWeakIsolatedPawn[4]).sub(penaltyWeakIsolatedPawn[4]).sub(penaltyDoubledPawn[4])
+	expect.Eq(t, score, expected)
+	expect.Eq(t, passers, Bitmask(0))
+}
+
+func TestEvaluatePawns730(t *testing.T) { // Backward pawn.
+	p := NewGame(`Ke1,d2,e3`, `Ke8,c4`).start()
+	score, passers := EvaluatePawns(p, White)
+	expected := Score{}
+	expected.sub(pawnAlone).sub(penaltyWeakBackwardPawn[3])
+	expect.Eq(t, score, expected)
+	expect.Eq(t, passers, bit[E3])
+}
+
+// Candidate passer: a4xb5 creates passed pawn while a2 just faces b7 with
+// the majority behind it.
+func TestEvaluatePawns740(t *testing.T) {
+	p := NewGame(`Ke1,a4,b4`, `Ke8,b5`).start()
+	expect.True(t, eval.init(p).isCandidatePasser(White, A4))
+	expect.False(t, eval.isCandidatePasser(White, B4))
+	score, _ := EvaluatePawns(p, White)
+	expect.Eq(t, score, bonusCandidatePasser[3])
+
+	p = NewGame(`Ke1,a2,b2`, `Ke8,b7`).start()
+	expect.True(t, eval.init(p).isCandidatePasser(White, A2))
+	score, _ = EvaluatePawns(p, White)
+	expect.Eq(t, score, bonusCandidatePasser[1])
+
+	p = NewGame(`Ke1,a2`, `Ke8,b7,c7`).start() // Outnumbered.
+	expect.False(t, eval.init(p).isCandidatePasser(White, A2))
+}
+
+// Passed pawns API matches the passers cached by pawn structure evaluation.
+func TestEvaluatePawns750(t *testing.T) {
+	p := NewGame(`Ke1,a5,d4,g2`, `Ke8,c6,h7`).start()
+	expect.Eq(t, p.PassedPawns(White), bit[A5])
+	expect.Eq(t, p.PassedPawns(Black), Bitmask(0))
+
+	p = NewGame(`Ke1,e4,e5`, `Ke8,a7`).start() // Doubled: rear pawn is not a passer.
+	expect.Eq(t, p.PassedPawns(White), bit[E5])
+	expect.Eq(t, p.PassedPawns(Black), bit[A7])
+
+	p = NewGame(`Ke1,b4,c5,f2`, `Ke8,b5,g7`).start()
+	eval.init(p).RunTerm(`pawns`)
+	expect.Eq(t, p.PassedPawns(White), eval.pawns.passers[White])
+	expect.Eq(t, p.PassedPawns(Black), eval.pawns.passers[Black])
+	expect.Eq(t, p.PassedPawns(White), bit[C5])
+}
+
+// Tarrasch rule: rooks belong behind passed pawns.
+func TestEvaluatePawns760(t *testing.T) {
+	p := NewGame(`Kg2,Ra1,a5`, `Kg7,Rb6`).start()
+	expect.Eq(t, eval.init(p).rookBehindPasser(White, A5), rookBehindPasser.times(4))
+
+	p = NewGame(`Kg2,Ra8,a5`, `Kg7,Rb6`).start() // Rook in front of the passer.
+	expect.Eq(t, eval.init(p).rookBehindPasser(White, A5), Score{})
+
+	p = NewGame(`Kg2,Rb1,a5`, `Kg7,Ra1`).start()
+	expect.Eq(t, eval.init(p).rookBehindPasser(White, A5), Score{0, -rookBehindEnemy.endgame * 4})
+
+	p = NewGame(`Kg2,Ra1,Na3,a5`, `Kg7,Rb6`).start() // Knight in between.
+	expect.Eq(t, eval.init(p).rookBehindPasser(White, A5), Score{})
+
+	engine.trace = true; defer func() { engine.trace = false }()
+	behind, metrics := NewGame(`Kg2,Ra1,a5`, `Kg7,Rb6`).start().EvaluateWithTrace()
+	expect.Eq(t, metrics[`-Tarrasch`].(Total).white, rookBehindPasser.times(4))
+	aside, _ := NewGame(`Kg2,Rh1,a5`, `Kg7,Rb6`).start().EvaluateWithTrace()
+	expect.True(t, behind > aside)
 }
@@ -11,12 +11,13 @@ package donna
 func (e *Evaluation) analyzePieces() {
 	p := e.position
 	var bonus, score Score
-	var knight, bishop, rook, queen, mobility Total
+	var knight, bishop, rook, queen, mobility, restriction Total
 
 	if engine.trace {
 		defer func() {
 			var our, their Score
 			e.checkpoint(`Mobility`, mobility)
+			e.checkpoint(`Restriction`, restriction)
 			e.checkpoint(`+Pieces`,  Total{*our.add(knight.white).add(bishop.white).add(rook.white).add(queen.white),
 				*their.add(knight.black).add(bishop.black).add(rook.black).add(queen.black)})
 			e.checkpoint(`-Knights`, knight)
@@ -26,18 +27,8 @@ func (e *Evaluation) analyzePieces() {
 		}()
 	}
 
-	// Mobility masks for both sides exclude squares attacked by opponent's pawns,
-	// king squares, pawns on first two ranks, and blocked pawns on other ranks.
-	var pawnExclusions = [2]Bitmask {
-		p.outposts[Pawn] & (maskRank[A2H2] | maskRank[A3H3] | p.board.up(Black)),
-		p.outposts[BlackPawn] & (maskRank[A7H7] | maskRank[A6H6] | p.board.up(White)),
-	}
-
 	// Initialize safe mobility zones for both sides.
-	var maskSafe = [2]Bitmask {
-		^(e.attacks[BlackPawn] | p.outposts[King] | pawnExclusions[White]),
-		^(e.attacks[Pawn] | p.outposts[BlackKing] | pawnExclusions[Black]),
-	}
+	var maskSafe = [2]Bitmask { e.mobilityMask(White), e.mobilityMask(Black) }
 
 	// Initialize flags to see if kings for both sides require safety evaluation.
 	var isKingUnsafe = [2]bool { e.isKingUnsafe(White), e.isKingUnsafe(Black) }
@@ -104,12 +95,95 @@ func (e *Evaluation) analyzePieces() {
 	e.attacks[Black] |= e.attacks[BlackKnight] | e.attacks[BlackBishop] | e.attacks[BlackRook] | e.attacks[BlackQueen]
 
 	// Calculate total mobility score applying mobility weight.
-	score.add(mobility.white).sub(mobility.black).apply(weightMobility)
+	score.add(mobility.white).sub(mobility.black).apply(weightMobility).apply(engine.activityWeight())
 	e.score.add(score)
+
+	// Bonus for squares denied to enemy's pieces.
+	restriction.white, restriction.black = e.restriction(White), e.restriction(Black)
+	e.score.add(restriction.white).sub(restriction.black)
+
+	// Compensation for being down the exchange.
+	e.score.add(e.exchangeSacrifice(White)).sub(e.exchangeSacrifice(Black))
+
+	// Penalty for weak pawns left without piece defenders.
+	e.score.add(e.helplessPawns(White)).sub(e.helplessPawns(Black))
+}
+
+// Returns penalty for weak, i.e. isolated and backward pawns that are not
+// defended by our pieces, and none of our pieces can step in to defend them
+// with a single move. In pawn endings the king is the only defender, and weak
+// pawns are already taken care of by the pawn structure evaluation.
+func (e *Evaluation) helplessPawns(our int) (score Score) {
+	p, their := e.position, our^1
+	if (p.outposts[our] ^ p.outposts[pawn(our)] ^ p.outposts[king(our)]).empty() {
+		return
+	}
+
+	defended := e.attacks[knight(our)] | e.attacks[bishop(our)] | e.attacks[rook(our)] | e.attacks[queen(our)] | e.attacks[king(our)]
+	free := ^(p.outposts[our] | e.attacks[pawn(their)])
+
+	for bm := e.pawns.weak[our] & ^defended; bm.any(); bm = bm.pop() {
+		square := bm.first()
+		diagonal, straight := p.bishopMoves(square), p.rookMoves(square)
+		reach := (knightMoves[square] & e.attacks[knight(our)]) |
+			 (diagonal & (e.attacks[bishop(our)] | e.attacks[queen(our)])) |
+			 (straight & (e.attacks[rook(our)] | e.attacks[queen(our)]))
+		if (reach & free).empty() {
+			score.sub(weakHelpless)
+		}
+	}
+
+	return
+}
+
+// Returns compensation when we're down the exchange, i.e. have a minor piece
+// for enemy's rook. Compensating factors are the bishop pair, a knight on an
+// outpost, an extra pawn, and an attack against enemy's king.
+func (e *Evaluation) exchangeSacrifice(our int) (score Score) {
+	p, their := e.position, our^1
+	minors := func(color int) int {
+		return (p.outposts[knight(color)] | p.outposts[bishop(color)]).count()
+	}
+
+	if p.outposts[rook(their)].count() - p.outposts[rook(our)].count() != 1 || minors(our) - minors(their) != 1 {
+		return
+	}
+
+	factors := 0
+	if p.outposts[bishop(our)].count() > 1 {
+		factors++
+	}
+	if (p.outposts[knight(our)] & e.outposts(our)).any() {
+		factors++
+	}
+	if p.outposts[pawn(our)].count() > p.outposts[pawn(their)].count() {
+		factors++
+	}
+	if e.safety[their].attackers > 1 {
+		factors++
+	}
+
+	return *score.add(exchangeSac.times(min(factors, 3)))
+}
+
+// Counts squares that enemy's knights, bishops, rooks, and queens would be
+// able to move to if not for our pawns and pieces controlling them.
+func (e *Evaluation) restriction(our int) (score Score) {
+	p, their := e.position, our^1
+	pieces := p.outposts[their] ^ p.outposts[pawn(their)] ^ p.outposts[king(their)]
+
+	denied := 0
+	for bm := pieces; bm.any(); bm = bm.pop() {
+		square := bm.first()
+		denied += (p.attacks(square) & ^p.outposts[their] & e.attacks[our]).count()
+	}
+
+	return *score.add(restrictMove.times(denied))
 }
 
 func (e *Evaluation) knights(our int, maskSafe Bitmask, unsafeKing bool) (score, mobility Score) {
 	p, their := e.position, our^1
+	outposts := e.outposts(our)
 
 	for bm := p.outposts[knight(our)]; bm.any(); bm = bm.pop() {
 		square := bm.first()
@@ -121,6 +195,28 @@ func (e *Evaluation) knights(our int, maskSafe Bitmask, unsafeKing bool) (score,
 			mobility.add(mobilityKnight[(attacks & maskSafe).count()])
 		}
 
+		// Bonus if the knight can reach an outpost square within two moves,
+		// penalty if it can't and enemy pawns are hemming it in.
+		if outposts.on(square) || (e.knightReach(square, our) & outposts).any() {
+			score.add(knightReach)
+		} else if (knightMoves[square] & e.attacks[pawn(their)]).count() >= 2 && (knightMoves[square] & maskSafe).count() <= 3 {
+			score.sub(knightBad)
+		}
+
+		// Bonus for knight sitting on an outpost in enemy's half of the board.
+		if outposts.on(square) {
+			score.add(e.outpostBonus(our, square, bonusKnightOutpost))
+		}
+
+		// Knight on the rim is dim, and knight in the corner is even dimmer.
+		if (maskFile[0] | maskFile[7]).on(square) {
+			if (maskRank[0] | maskRank[7]).on(square) {
+				score.sub(knightInCorner)
+			} else {
+				score.sub(knightOnRim)
+			}
+		}
+
 		// Penalty if knight is attacked by enemy's pawn.
 		if (maskPawn[their][square] & p.outposts[pawn(their)]).any() {
 			score.sub(penaltyPawnThreat[Knight/2])
@@ -145,6 +241,12 @@ func (e *Evaluation) knights(our int, maskSafe Bitmask, unsafeKing bool) (score,
 
 func (e *Evaluation) bishops(our int, maskSafe Bitmask, unsafeKing bool) (score, mobility Score) {
 	p, their := e.position, our^1
+	outposts := e.outposts(our)
+
+	// Bonus for having both light and dark squared bishops.
+	if bishops := p.outposts[bishop(our)]; (bishops & maskDark).any() && (bishops & ^maskDark).any() {
+		score.add(bonusBishopPair)
+	}
 
 	for bm := p.outposts[bishop(our)]; bm.any(); bm = bm.pop() {
 		square := bm.first()
@@ -157,9 +259,23 @@ func (e *Evaluation) bishops(our int, maskSafe Bitmask, unsafeKing bool) (score,
 		mobility.add(mobilityBishop[(attacks & maskSafe).count()])
 
 
-		// Penalty for light/dark-colored pawns restricting a bishop.
-		if count := (same(square) & p.outposts[pawn(our)]).count(); count > 0 {
-			score.sub(bishopPawn.times(count))
+		// Penalty for light/dark-colored pawns restricting a bishop. Our pawns
+		// fixed by enemy pawns on the bishop's color complex make it bad, and
+		// get bigger penalty instead.
+		fixed := int(e.pawns.fixedLight[our])
+		if (bit[square] & maskDark).any() {
+			fixed = int(e.pawns.fixedDark[our])
+		}
+		if count := (same(square) & p.outposts[pawn(our)]).count(); count > fixed {
+			score.sub(bishopPawn.times(count - fixed))
+		}
+		if fixed > 0 {
+			score.sub(badBishop.times(fixed))
+		}
+
+		// Bonus for bishop sitting on an outpost in enemy's half of the board.
+		if outposts.on(square) {
+			score.add(e.outpostBonus(our, square, bonusBishopOutpost))
 		}
 
 		// Penalty if bishop is attacked by enemy's pawn.
@@ -209,12 +325,20 @@ func (e *Evaluation) bishops(our int, maskSafe Bitmask, unsafeKing bool) (score,
 
 func (e *Evaluation) rooks(our int, maskSafe Bitmask, unsafeKing bool) (score, mobility Score) {
 	p, their := e.position, our^1
-	ourPawns := p.outposts[pawn(our)]
 	theirPawns := p.outposts[pawn(their)]
 
 	// Bonus if rook is on 7th rank and enemy's king trapped on 8th.
-	if bm := (p.outposts[rook(our)] & mask7th[our]); bm.any() && (p.outposts[king(their)] & mask8th[our]).any() {
-		score.add(rookOn7th.times(bm.count()))
+	if bm := (p.outposts[rook(our)] & mask7th[our]); bm.any() {
+		trapped := (p.outposts[king(their)] & mask8th[our]).any()
+		if trapped {
+			score.add(rookOn7th.times(bm.count()))
+		}
+
+		// Extra bonus for doubled rooks on 7th rank ("pigs on the 7th") that
+		// either trap enemy's king or feast on enemy's pawns.
+		if bm.count() > 1 && (trapped || (theirPawns & mask7th[our]).any()) {
+			score.add(rooksOn7th)
+		}
 	}
 
 	for bm := p.outposts[rook(our)]; bm.any(); bm = bm.pop() {
@@ -240,17 +364,27 @@ func (e *Evaluation) rooks(our int, maskSafe Bitmask, unsafeKing bool) (score, m
 			}
 		}
 
-		// Bonuses if rook is on open or semi-open file.
+		// Bonuses if rook is on open or semi-open file. Double the bonus
+		// if the open file leads to enemy's king.
 		column := col(square)
-		isFileAjar := (ourPawns & maskFile[column] == 0)
+		isFileAjar := (e.pawns.files[column] & (1 << uint(our)) == 0)
 		if isFileAjar {
-			if theirPawns & maskFile[column] == 0 {
+			if e.pawns.files[column] == 0 {
 				score.add(rookOnOpen)
+				if column == col(p.king[their]) {
+					score.add(rookOnOpen)
+				}
 			} else {
 				score.add(rookOnSemiOpen)
 			}
 		}
 
+		// Middle game bonus if the rook can be lifted to join the attack
+		// on enemy's castled king.
+		if rank(our, square) <= A2H2 && e.rookLift(square, our) {
+			score.add(rookLift)
+		}
+
 		// Middle game penalty if a rook is boxed. Extra penalty if castle
 		// rights have been lost.
 		if safeSquares <= 3 || !isFileAjar {
@@ -284,6 +418,29 @@ func (e *Evaluation) rooks(our int, maskSafe Bitmask, unsafeKing bool) (score, m
 	return
 }
 
+// Returns true if the rook has clear path up to 3rd or 4th rank from where it
+// could swing over to the file of enemy's castled king.
+func (e *Evaluation) rookLift(from, our int) bool {
+	p, their := e.position, our^1
+	king := p.king[their]
+
+	// Enemy's king should be castled on either side of the board.
+	if rank(their, king) > A2H2 || (col(king) > C1C8 && col(king) < F1F8) {
+		return false
+	}
+
+	for _, row := range [2]int{ A3H3, A4H4 } {
+		row = let(our == White, row, 7 - row)
+		lift, swing := square(row, col(from)), square(row, col(king))
+		if lift != swing && e.attacks[pawn(their)].off(lift) &&
+		   (maskBlock[from][lift] & p.board).empty() && (maskBlock[lift][swing] & p.board).empty() {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (e *Evaluation) queens(our int, maskSafe Bitmask, unsafeKing bool) (score, mobility Score) {
 	p, their := e.position, our^1
 
@@ -302,6 +459,17 @@ func (e *Evaluation) queens(our int, maskSafe Bitmask, unsafeKing bool) (score,
 			score.sub(penaltyPawnThreat[Queen/2])
 		}
 
+		// Steep penalty if the queen has wandered deep into enemy's territory
+		// and has very few safe squares to escape to. The safe squares mask
+		// excludes enemy pawn, minor, and rook attacks which are known for
+		// both sides at this point.
+		if rank(our, square) > A4H4 {
+			safe := attacks & maskSafe & ^p.outposts[our]
+			if count := safe.count(); count < 3 {
+				score.sub(queenTrapped.times(3 - count))
+			}
+		}
+
 		// Track if queen attacks squares around enemy's king.
 		if unsafeKing {
 			e.kingThreats(queen(our), attacks)
@@ -314,6 +482,61 @@ func (e *Evaluation) queens(our int, maskSafe Bitmask, unsafeKing bool) (score,
 	return
 }
 
+// Returns a bitmask of squares that count toward our pieces mobility. It
+// excludes squares attacked by enemy pawns since a pawn trivially chases the
+// piece away, our king, our pawns on first two ranks, and our blocked pawns
+// on other ranks.
+func (e *Evaluation) mobilityMask(our int) Bitmask {
+	p, their := e.position, our^1
+
+	home := maskRank[A2H2] | maskRank[A3H3]
+	if our == Black {
+		home = maskRank[A7H7] | maskRank[A6H6]
+	}
+	pawns := p.outposts[pawn(our)] & (home | p.board.up(their))
+
+	return ^(e.attacks[pawn(their)] | p.outposts[king(our)] | pawns)
+}
+
+// Returns a bitmask of outpost squares for the given side, i.e. squares on 4th
+// to 6th ranks that are defended by our pawns and can't be attacked by enemy
+// pawns.
+func (e *Evaluation) outposts(our int) (bitmask Bitmask) {
+	p, their := e.position, our^1
+
+	bitmask = e.attacks[pawn(our)] & (maskRank[A4H4] | maskRank[A5H5] | maskRank[A6H6])
+	if our == Black {
+		bitmask = e.attacks[pawn(our)] & (maskRank[A5H5] | maskRank[A4H4] | maskRank[A3H3])
+	}
+
+	// Exclude squares that enemy pawns could attack as they advance.
+	return bitmask & ^pawnAttackSpan(p.outposts[pawn(their)].forward(their), their)
+}
+
+// Returns outpost bonus for the minor piece indexed by rank. The bonus goes up
+// by half if the outpost is on one of the central files.
+func (e *Evaluation) outpostBonus(our, square int, bonus [8]Score) (score Score) {
+	score = bonus[rank(our, square)]
+	if column := col(square); column >= C1 && column <= F1 {
+		score.add(Score{score.midgame / 2, score.endgame / 2})
+	}
+
+	return
+}
+
+// Returns a bitmask of squares the knight can reach in one or two moves without
+// stepping on friendly pieces.
+func (e *Evaluation) knightReach(square int, our int) (bitmask Bitmask) {
+	free := ^e.position.outposts[our]
+
+	bitmask = knightMoves[square] & free
+	for bm := bitmask; bm.any(); bm = bm.pop() {
+		bitmask |= knightMoves[bm.first()] & free
+	}
+
+	return bitmask
+}
+
 // Updates safety data used later on when evaluating king safety.
 func (e *Evaluation) kingThreats(piece Piece, attacks Bitmask) {
 	their := piece.color()^1
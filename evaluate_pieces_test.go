@@ -0,0 +1,231 @@
+// Copyright (c) 2014-2018 by Michael Dvorkin. All Rights Reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+//
+// I am making my contributions/submissions to this project solely in my
+// personal capacity and am not conveying any rights to any intellectual
+// property of any third parties.
+
+package donna
+
+import(`github.com/michaeldv/donna/expect`; `testing`)
+
+// Knights.
+func TestEvaluatePieces000(t *testing.T) { // Knight can reach D5 outpost.
+	p := NewGame(`Kg1,Nc3,c4,e4`, `Kg8,a7,h7`).start()
+	_, metrics := p.EvaluateWithTrace()
+	knight := metrics[`-Knights`].(Total).white
+
+	expect.True(t, knight.midgame > 0)
+}
+
+func TestEvaluatePieces010(t *testing.T) { // Knight is boxed in by enemy pawns.
+	p := NewGame(`Kg1,Na4,a2`, `Kg8,b6,d4`).start()
+	_, metrics := p.EvaluateWithTrace()
+	knight := metrics[`-Knights`].(Total).white
+
+	expect.True(t, knight.midgame < 0)
+}
+
+func TestEvaluatePieces020(t *testing.T) {
+	p := NewGame(`Kg1,Nc3,c4,e4`, `Kg8,c6,h7`).start() // C6 pawn covers D5.
+	eval.init(p)
+	expect.Eq(t, eval.outposts(White), bit[F5])
+
+	p = NewGame(`Kg1,Nc3,c4,e4`, `Kg8,a7,h7`).start()
+	eval.init(p)
+	expect.Eq(t, eval.outposts(White), bit[D5] | bit[F5])
+}
+
+// Squares denied to the enemy's knight boxed in the corner.
+func TestEvaluatePieces030(t *testing.T) {
+	p := NewGame(`Kg1,a5,d6`, `Kg8,Na8`).start()
+	_, metrics := p.EvaluateWithTrace()
+	restriction := metrics[`Restriction`].(Total)
+
+	expect.Eq(t, restriction.white, restrictMove.times(2))
+	expect.Eq(t, restriction.black, Score{0, 0})
+}
+
+// Rooks.
+func TestEvaluatePieces100(t *testing.T) { // Doubled rooks on 7th against back rank king.
+	p := NewGame(`Kg1,Ra7,Rb7`, `Kg8,Ra2,Rb2`).start()
+	_, metrics := p.EvaluateWithTrace()
+	rooks := metrics[`-Rooks`].(Total).white
+
+	p = NewGame(`Kg1,Ra7,Rb1`, `Kg8,Ra2,Rb2`).start()
+	_, metrics = p.EvaluateWithTrace()
+	rook := metrics[`-Rooks`].(Total).white
+
+	expect.True(t, rooks.midgame - rook.midgame >= rooksOn7th.midgame + rookOn7th.midgame)
+	expect.True(t, rooks.endgame - rook.endgame >= rooksOn7th.endgame + rookOn7th.endgame)
+}
+
+func TestEvaluatePieces110(t *testing.T) { // Rook lift Rd1-d3-g3 vs king in the center.
+	p := NewGame(`Kg1,Rd1,a2,b2,c2,f2,g2,h2`, `Kg8,a7,b7,c7,f7,g7,h7`).start()
+	_, metrics := p.EvaluateWithTrace()
+	lift := metrics[`-Rooks`].(Total).white
+
+	p = NewGame(`Kg1,Rd1,a2,b2,c2,f2,g2,h2`, `Ke8,a7,b7,c7,f7,g7,h7`).start()
+	_, metrics = p.EvaluateWithTrace()
+	rook := metrics[`-Rooks`].(Total).white
+
+	expect.Eq(t, lift.midgame - rook.midgame, rookLift.midgame)
+}
+
+// Queens.
+func TestEvaluatePieces200(t *testing.T) { // Poisoned pawn: Qxb2, Rb1 Qa3, Rb3.
+	p := NewGame(`Kg1,Qc1,Rb3,Bd2,Nc3,a2,c2,e4,f2,g2,h2`, `M,Kg8,Qa3,Ra8,Bc8,Nb8,a6,c5,d6,e5,f7,g7,h7`).start()
+	_, metrics := p.EvaluateWithTrace()
+	queen := metrics[`-Queens`].(Total).black
+
+	expect.Eq(t, queen, Score{ -queenTrapped.midgame * 2, -queenTrapped.endgame * 2 })
+
+	// Same position with colors flipped.
+	p = NewGame(`Kg1,Qa6,Ra1,Bc1,Nb1,a3,c4,d3,e4,f2,g2,h2`, `Kg8,Qc8,Rb6,Bd7,Nc6,a7,c7,e5,f7,g7,h7`).start()
+	_, metrics = p.EvaluateWithTrace()
+	queen = metrics[`-Queens`].(Total).white
+
+	expect.Eq(t, queen, Score{ -queenTrapped.midgame * 2, -queenTrapped.endgame * 2 })
+
+	p = NewGame(`Kg1,Qc1,Rb3,Bd2,Nc3,a2,c2,e4,f2,g2,h2`, `M,Kg8,Qd8,Ra8,Bc8,Nb8,a6,c5,d6,e5,f7,g7,h7`).start()
+	_, metrics = p.EvaluateWithTrace()
+	queen = metrics[`-Queens`].(Total).black
+
+	expect.Eq(t, queen, Score{0, 0})
+}
+
+func TestEvaluatePieces300(t *testing.T) { // Exchange sacrifice: bishop pair, knight on d4 outpost, and extra pawn.
+	p := NewGame(`2bq1rk1/pp3pbp/3p2p1/4p3/3nP3/2N5/PP3PPP/R1BQ1RK1 w - - 0 1`).start()
+	score := p.Evaluate()
+
+	expect.Eq(t, eval.exchangeSacrifice(Black), exchangeSac.times(3))
+	expect.Eq(t, eval.exchangeSacrifice(White), Score{0, 0})
+	expect.True(t, abs(score) < onePawn / 2)
+}
+
+func TestEvaluatePieces310(t *testing.T) { // Isolated d4 pawn with and without the rook able to defend it.
+	p := NewGame(`Kg1,Rh1,d4,h2`, `Kg8,Rd8,a7,h7`).start()
+	eval.init(p).RunTerm(`pieces`)
+	expect.Eq(t, eval.pawns.weak[White] & bit[D4], bit[D4])
+	helpless := eval.helplessPawns(White)
+
+	p = NewGame(`Kg1,Rb1,d4,h2`, `Kg8,Rd8,a7,h7`).start()
+	eval.init(p).RunTerm(`pieces`)
+	defended := eval.helplessPawns(White)
+
+	expect.Eq(t, helpless, Score{ -weakHelpless.midgame, -weakHelpless.endgame })
+	expect.True(t, helpless.midgame < defended.midgame && helpless.endgame < defended.endgame)
+}
+
+func TestEvaluatePieces320(t *testing.T) { // Rook on fully open d-file with and without enemy king on the file.
+	p := NewGame(`Kg1,Rd1,a2,b2,h2`, `Ke8,a7,b7,h7`).start()
+	eval.init(p).RunTerm(`pieces`)
+	expect.Eq(t, eval.pawns.files[D1], uint8(0))
+	expect.Eq(t, eval.pawns.files[A1], uint8(3))
+	expect.Eq(t, eval.pawns.files[H1], uint8(3))
+	open, _ := eval.rooks(White, maskFull, false)
+
+	p = NewGame(`Kg1,Rd1,a2,b2,h2`, `Kd8,a7,b7,h7`).start()
+	eval.init(p).RunTerm(`pieces`)
+	facing, _ := eval.rooks(White, maskFull, false)
+
+	expect.Eq(t, facing.midgame - open.midgame, rookOnOpen.midgame)
+	expect.Eq(t, facing.endgame - open.endgame, rookOnOpen.endgame)
+}
+
+func TestEvaluatePieces330(t *testing.T) { // Knight on the rim and in the corner.
+	knight := func(square string) (score Score, blended int) {
+		p := NewGame(`Kg1,N` + square + `,f2,g2,h2`, `Kg8,f7,g7,h7`).start()
+		blended = p.Evaluate()
+		score, _ = eval.knights(White, maskFull, false)
+		return
+	}
+	central, c4 := knight(`c4`)
+	rim, a4 := knight(`a4`)
+	corner, a1 := knight(`a1`)
+
+	expect.Eq(t, central.midgame - rim.midgame, knightOnRim.midgame)
+	expect.Eq(t, central.midgame - corner.midgame, knightInCorner.midgame)
+	expect.True(t, c4 > a4 && a4 > a1)
+}
+
+// Bishop pair and bad bishop hemmed by its own pawns fixed on the same color.
+func TestEvaluatePieces340(t *testing.T) {
+	bishops := func(white, black string) (score Score, blended int) {
+		blended = NewGame(white, black).start().Evaluate()
+		score, _ = eval.bishops(White, maskFull, false)
+		return
+	}
+	pair, _ := bishops(`Kg1,Bc1,Bf1,a2`, `Kg8,a7`)
+	dark, _ := bishops(`Kg1,Bc1,a2`, `Kg8,a7`)
+	light, _ := bishops(`Kg1,Bf1,a2`, `Kg8,a7`)
+	expect.Eq(t, pair, *dark.add(light).add(bonusBishopPair))
+
+	// French-style locked center with d4,e5 pawns fixed on dark squares.
+	bad, worse := bishops(`Kg1,Bc1,d4,e5`, `Kg8,Bc8,d5,e6`)
+	expect.Eq(t, eval.pawns.fixedDark[White], uint8(2))
+	expect.Eq(t, eval.pawns.fixedLight[White], uint8(0))
+	expect.Eq(t, eval.pawns.fixedLight[Black], uint8(2))
+
+	good, better := bishops(`Kg1,Bc1,d4,e5`, `Kg8,Bc8,a7,b7`)
+	penalty := badBishop.times(2) // Instead of regular bishopPawn penalty.
+	expect.Eq(t, *good.sub(bad), *penalty.sub(bishopPawn.times(2)))
+
+	_, other := bishops(`Kg1,Bf1,d4,e5`, `Kg8,Bc8,d5,e6`)
+	expect.True(t, worse < other)
+	expect.True(t, better > worse)
+}
+
+// Knight and bishop outposts supported by pawns.
+func TestEvaluatePieces350(t *testing.T) {
+	knight := func(white, black string) (score Score) {
+		NewGame(white, black).start().Evaluate()
+		score, _ = eval.knights(White, maskFull, false)
+		return
+	}
+	outpost := knight(`Kg1,Nd5,c4,g2`, `Kg8,a7,f7,g7`)
+	unsupported := knight(`Kg1,Nd5,c3,g2`, `Kg8,a7,f7,g7`)
+	expect.Eq(t, *outpost.sub(unsupported), Score{18 + knightReach.midgame, 6 + knightReach.endgame}) // Central file on 5th rank.
+
+	// Can be attacked by enemy pawn.
+	attackable := knight(`Kg1,Nd5,c4,g2`, `Kg8,a7,e7,g7`)
+	expect.Eq(t, eval.outposts(White).on(D5), false)
+	expect.True(t, attackable.midgame < knight(`Kg1,Nd5,c4,g2`, `Kg8,a7,f7,g7`).midgame)
+
+	// Outpost on 6th rank and on the edge file.
+	sixth := knight(`Kg1,Ne6,d5,g2`, `Kg8,a7,g7`)
+	expect.Eq(t, eval.outpostBonus(White, E6, bonusKnightOutpost), Score{27, 9})
+	expect.Eq(t, eval.outpostBonus(White, B5, bonusKnightOutpost), bonusKnightOutpost[A5H5])
+	expect.True(t, sixth.midgame > 0)
+
+	// Bishop outpost.
+	NewGame(`Kg1,Be5,d4,g2`, `Kg8,a7,h7`).start().Evaluate()
+	bishop, _ := eval.bishops(White, maskFull, false)
+	NewGame(`Kg1,Be5,d3,g2`, `Kg8,a7,h7`).start().Evaluate()
+	loose, _ := eval.bishops(White, maskFull, false)
+	bonus := eval.outpostBonus(White, E5, bonusBishopOutpost)
+	expect.Eq(t, *bishop.sub(loose), *bonus.sub(bishopPawn))
+}
+
+// Mobility counts squares not attacked by enemy pawns and not occupied by our
+// king, pawns on first two ranks, or blocked pawns.
+func TestEvaluatePieces360(t *testing.T) {
+	p := NewGame(`r1bqk2r/pppp1ppp/2n2n2/2b1p3/2B1P3/2NP1N2/PPP2PPP/R1BQK2R b KQkq - 0 5`).start()
+	p.Evaluate()
+	mask := eval.mobilityMask(White)
+
+	expect.False(t, mask.on(D4)) // Attacked by e5 pawn.
+	expect.False(t, mask.on(E4)) // Blocked pawn.
+	expect.False(t, mask.on(A2)) // Pawn on second rank.
+	expect.False(t, mask.on(E1)) // King.
+	expect.True(t, mask.on(D1))  // Other pieces count.
+
+	expect.Eq(t, (p.attacks(C3) & mask).count(), 6)
+	expect.Eq(t, (p.attacks(F3) & mask).count(), 5)
+	expect.Eq(t, (p.xrayAttacks(C4) & mask).count(), 4)
+
+	_, mobility := eval.knights(White, mask, false)
+	expected := mobilityKnight[6]
+	expect.Eq(t, mobility, *expected.add(mobilityKnight[5]))
+}
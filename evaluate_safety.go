@@ -10,30 +10,41 @@ package donna
 
 func (e *Evaluation) analyzeSafety() {
 	var score Score
-	var cover, safety Total
+	var cover, storm, safety, tropism Total
 
 	if engine.trace {
 		defer func() {
 			var our, their Score
-			e.checkpoint(`+King`, Total{*our.add(cover.white).add(safety.white), *their.add(cover.black).add(safety.black)})
+			e.checkpoint(`+King`, Total{*our.add(cover.white).add(storm.white).add(safety.white).add(tropism.white), *their.add(cover.black).add(storm.black).add(safety.black).add(tropism.black)})
 			e.checkpoint(`-Cover`, cover)
+			e.checkpoint(`-Storm`, storm)
 			e.checkpoint(`-Safety`, safety)
+			e.checkpoint(`-Tropism`, tropism)
 		}()
 	}
 
-	// If any of the pawns or a king have moved then recalculate cover score.
-	if e.position.king[White] != e.pawns.king[White] {
-		e.pawns.cover[White] = e.kingCover(White)
+	// If any of the pawns or a king have moved then recalculate cover score
+	// of the king along with enemy pawn storm against it. Lost castle rights
+	// affect the cover too even if the king stays put.
+	castles := e.position.castles != e.pawns.castles
+	if castles || e.position.king[White] != e.pawns.king[White] {
+		cover, storm := e.kingCover(White)
+		e.pawns.cover[White], e.pawns.storm[Black] = cover, int16(storm)
 		e.pawns.king[White] = e.position.king[White]
 	}
-	if e.position.king[Black] != e.pawns.king[Black] {
-		e.pawns.cover[Black] = e.kingCover(Black)
+	if castles || e.position.king[Black] != e.pawns.king[Black] {
+		cover, storm := e.kingCover(Black)
+		e.pawns.cover[Black], e.pawns.storm[White] = cover, int16(storm)
 		e.pawns.king[Black] = e.position.king[Black]
 	}
+	e.pawns.castles = e.position.castles
 
-	// Fetch king cover score from the pawn cache.
+	// Fetch king cover and pawn storm score from the pawn cache. Advanced
+	// pawns near enemy king depend on the queens, so they don't get cached.
 	cover.white.add(e.pawns.cover[White])
 	cover.black.add(e.pawns.cover[Black])
+	storm.white.midgame = int(e.pawns.storm[White]) + e.pawnsNearKing(White)
+	storm.black.midgame = int(e.pawns.storm[Black]) + e.pawnsNearKing(Black)
 
 	// Calculate king's safety for both sides.
 	if e.safety[White].threats > 0 {
@@ -43,15 +54,63 @@ func (e *Evaluation) analyzeSafety() {
 		safety.black = e.kingSafety(Black)
 	}
 
+	// Bonus for pieces being close to enemy's king.
+	tropism.white, tropism.black = e.kingTropism(White), e.kingTropism(Black)
+
 	// Calculate total king safety and pawn cover score.
 	score.add(safety.white).sub(safety.black).apply(weightSafety)
-	score.add(cover.white).sub(cover.black)
+	score.add(cover.white).sub(cover.black).add(storm.white).sub(storm.black)
+	e.score.add(score)
+
+	score.clear().add(tropism.white).sub(tropism.black).apply(engine.activityWeight())
 	e.score.add(score)
 }
 
+// Sums up proximity of our pieces to enemy's king weighted by piece kind.
+// Closer pieces have more attacking potential even without concrete threats.
+func (e *Evaluation) kingTropism(our int) (score Score) {
+	p, square := e.position, e.position.king[our^1]
+
+	pieces := p.outposts[our] ^ p.outposts[pawn(our)] ^ p.outposts[king(our)]
+	for bm := pieces; bm.any(); bm = bm.pop() {
+		sq := bm.first()
+		score.midgame += kingTropism[p.pieces[sq].kind() / 2] * (7 - distance[sq][square])
+	}
+
+	return
+}
+
 func (e *Evaluation) kingSafety(our int) (score Score) {
+	danger, checkers := e.kingDanger(our)
+	score.midgame -= kingSafety[min(63, max(0, danger))]
+
+	if checkers > 0 {
+		score.add(rightToMove)
+		if checkers > 1 {
+			score.add(rightToMove)
+		}
+	}
+
+	return score
+}
+
+// Returns raw king danger for the given side, i.e. the sum of attack units
+// before it gets looked up in the king safety table. The position must be
+// evaluated first so that attack and king safety data is available.
+func (e *Evaluation) KingDanger(color int) int {
+	if e.safety[color].threats == 0 {
+		return 0
+	}
+
+	danger, _ := e.kingDanger(color)
+	return danger
+}
+
+// Sums up attack units against our king and counts the number of enemy
+// pieces that can give a safe check.
+func (e *Evaluation) kingDanger(our int) (danger, checkers int) {
 	p, their := e.position, our^1
-	safetyIndex, checkers, square := 0, 0, p.king[our]
+	safetyIndex, square := 0, p.king[our]
 
 	// Find squares around the king that are being attacked by the
 	// enemy and defended by our king only.
@@ -105,43 +164,69 @@ func (e *Evaluation) kingSafety(our int) (score Score) {
 
 	threatIndex := min(16, e.safety[our].attackers * e.safety[our].threats / 2) +
 			(e.safety[our].attacks + weak.count()) * 3 +
-			rank(our, square) - e.pawns.cover[our].midgame / 16
-	safetyIndex = min(63, max(0, safetyIndex + threatIndex))
-
-	score.midgame -= kingSafety[safetyIndex]
+			rank(our, square) - (e.pawns.cover[our].midgame - int(e.pawns.storm[their])) / 16
 
-	if checkers > 0 {
-		score.add(rightToMove)
-		if checkers > 1 {
-			score.add(rightToMove)
-		}
-	}
-
-	return score
+	return safetyIndex + threatIndex, checkers
 }
 
-func (e *Evaluation) kingCover(our int) (score Score) {
+// Returns king cover score along with the storm score of enemy pawns facing
+// the king. The storm gets scored for the enemy.
+func (e *Evaluation) kingCover(our int) (cover Score, storm int) {
 	p, square := e.position, e.position.king[our]
 
 	// Don't bother with the cover if the king is too far out.
 	if rank(our, square) <= A3H3 {
 		// If we still have castle rights encourage castle pawns to stay intact
 		// by scoring least safe castle.
-		score.midgame = e.kingCoverBonus(our, square)
+		shelter, attack := e.kingCoverBonus(our, square)
 		if p.castles & castleKingside[our] != 0 {
-			score.midgame = max(score.midgame, e.kingCoverBonus(our, homeKing[our] + 2))
+			if bonus, storm := e.kingCoverBonus(our, G1 + our * A8); bonus - storm > shelter - attack {
+				shelter, attack = bonus, storm
+			}
 		}
 		if p.castles & castleQueenside[our] != 0 {
-			score.midgame = max(score.midgame, e.kingCoverBonus(our, homeKing[our] - 2))
+			if bonus, storm := e.kingCoverBonus(our, C1 + our * A8); bonus - storm > shelter - attack {
+				shelter, attack = bonus, storm
+			}
 		}
+		cover.midgame, storm = shelter, attack
+	}
+
+	cover.endgame = e.kingPawnProximity(our, square)
+
+	return cover, storm
+}
+
+// Rewards our pawns advanced into the zone around enemy king whether they
+// are passed or not. Pawns closer to the king get bigger bonus, and connected
+// pawns get rewarded more since they are harder to stop. The pawns only pose
+// mating threats while we still have the queen.
+func (e *Evaluation) pawnsNearKing(our int) (score int) {
+	p, square := e.position, e.position.king[our^1]
+	pawns := p.outposts[pawn(our)]
+	if p.outposts[queen(our)].empty() {
+		return 0
 	}
 
-	score.endgame = e.kingPawnProximity(our, square)
+	for bm := pawns; bm.any(); bm = bm.pop() {
+		sq := bm.first()
+		if rank(our, sq) < A5H5 || distance[sq][square] > 2 {
+			continue
+		}
+		bonus := bonusKingPawn[rank(our, sq)] * (4 - distance[sq][square])
+		row, col := coordinate(sq)
+		if (pawns & maskIsolated[col] & (maskRank[row] | maskRank[row].up(our^1))).any() {
+			bonus += bonus / 2
+		}
+		score += bonus
+	}
 
 	return score
 }
 
-func (e *Evaluation) kingCoverBonus(our int, square int) (bonus int) {
+// Returns cover bonus of our pawns in front of the king on given square, and
+// storm score of enemy pawns advancing toward it.
+func (e *Evaluation) kingCoverBonus(our int, square int) (bonus, attack int) {
 	bonus = onePawn + onePawn / 3
 
 	// Get pawns adjacent to and in front of the king.
@@ -166,16 +251,16 @@ func (e *Evaluation) kingCoverBonus(our int, square int) (bonus int) {
 		if pawns := (storm & maskFile[c]); pawns.any() {
 			farthest := rank(our, pawns.farthest(our^1))
 			if closest == 0 { // No opposing friendly pawn.
-				bonus -= penaltyStorm[farthest]
+				attack += penaltyStorm[farthest]
 			} else if farthest == closest + 1 {
-				bonus -= penaltyStormBlocked[farthest]
+				attack += penaltyStormBlocked[farthest]
 			} else {
-				bonus -= penaltyStormUnblocked[farthest]
+				attack += penaltyStormUnblocked[farthest]
 			}
 		}
 	}
 
-	return bonus
+	return bonus, attack
 }
 
 // Calculates endgame penalty to encourage a king stay closer to friendly pawns.
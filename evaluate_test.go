@@ -38,7 +38,7 @@ func TestEvaluate030(t *testing.T) {
 	p := NewGame(`Ra1,Nb1,Bc1,Qd1,Ke1,Bf1,Nf3,Rh1,a2,b2,c2,d2,e4,f2,g2,h2`,
 		`M2,Ra8,Nb8,Bc8,Qd8,Ke8,Bf8,Ng8,Rh8,a7,b7,c7,d7,e5,f7,g7,h7`).start()
 	score := p.Evaluate()
-	expect.Eq(t, score, -95)
+	expect.Eq(t, score, -97)
 }
 
 // After 1. e2-e4 e7-e5 2. Ng1-f3 Ng8-f6
@@ -54,7 +54,7 @@ func TestEvaluate050(t *testing.T) {
 	p := NewGame(`Ra1,Nb1,Bc1,Qd1,Ke1,Bf1,Nf3,Rh1,a2,b2,c2,d2,e4,f2,g2,h2`,
 		`Ra8,Nc6,Bc8,Qd8,Ke8,Bf8,Ng8,Rh8,a7,b7,c7,d7,e5,f7,g7,h7`).start()
 	score := p.Evaluate()
-	expect.Eq(t, score, 3)
+	expect.Eq(t, score, -1)
 }
 
 // After 1. e2-e4 e7-e5 2. Ng1-f3 Nb8-c6 3. Nb1-c3 Ng8-f6
@@ -89,3 +89,148 @@ func TestEvaluate073(t *testing.T) {
 	eval.init(p)
 	expect.False(t, eval.oppositeBishops())
 }
+
+// King tropism.
+func TestEvaluate100(t *testing.T) {
+	p := NewGame(`Kg1,Qa1,f2,g2,h2`, `Kg8,f7,g7,h7`).start()
+	_, metrics := p.EvaluateWithTrace()
+	far := metrics[`-Tropism`].(Total).white
+
+	p = NewGame(`Kg1,Qd5,f2,g2,h2`, `Kg8,f7,g7,h7`).start()
+	_, metrics = p.EvaluateWithTrace()
+	near := metrics[`-Tropism`].(Total).white
+
+	expect.True(t, near.midgame > far.midgame)
+	expect.Eq(t, near.endgame, 0)
+}
+
+// Single evaluation term.
+func TestEvaluate110(t *testing.T) {
+	p := NewGame(`Ke1,a2,e5`, `Ke8,a7`).start()
+	passers := eval.init(p).RunTerm(`passers`)
+	expect.True(t, passers.midgame > 0)
+	expect.True(t, passers.endgame > 0)
+
+	p = NewGame(`Ke1,a2`, `Ke8,a7,d4`).start()
+	passers = eval.init(p).RunTerm(`passers`)
+	expect.True(t, passers.endgame < 0)
+
+	expect.Eq(t, eval.init(p).RunTerm(`bogus`), Score{0, 0})
+}
+
+// Evaluation after the move leaves the position intact.
+func TestEvaluate120(t *testing.T) {
+	p := NewGame(`Ke1,Qd1,e4`, `Ke8,Qd5,a7`).start()
+	before, current := *p, node
+
+	score, err := p.EvaluateAfter(NewMove(p, E4, D5))
+	expect.Eq(t, err, nil)
+	expect.True(t, score > onePawn * 5)
+	expect.True(t, *p == before)
+	expect.Eq(t, node, current)
+
+	_, err = p.EvaluateAfter(NewMove(p, D1, D8)) // Blocked by the queen.
+	expect.Ne(t, err, nil)
+	expect.True(t, *p == before)
+}
+
+// Activity weight scales mobility and other activity terms.
+func TestEvaluate130(t *testing.T) {
+	defer func() { engine.activity = 0 }()
+	fen := `r1bqkbnr/pppp1ppp/2n5/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R b KQkq - 3 3`
+
+	engine.activity = 50
+	low := eval.init(NewGame(fen).start()).RunTerm(`pieces`)
+
+	engine.activity = 0
+	normal := eval.init(NewGame(fen).start()).RunTerm(`pieces`)
+
+	engine.activity = 200
+	high := eval.init(NewGame(fen).start()).RunTerm(`pieces`)
+
+	expect.True(t, low.midgame < normal.midgame)
+	expect.True(t, normal.midgame < high.midgame)
+}
+
+// King danger goes up as more pieces join the attack.
+func TestEvaluate140(t *testing.T) {
+	black := `M,Kg8,Qd8,Ra8,Bc8,Nb8,a7,b7,c7,f7,g7,h7`
+	danger := -1
+	for _, white := range []string{
+		`Kg1,Qh5,a2,b2,c2,f2,g2,h2`,
+		`Kg1,Qh5,Ng5,a2,b2,c2,f2,g2,h2`,
+		`Kg1,Qh5,Ng5,Bd3,a2,b2,c2,f2,g2,h2`,
+		`Kg1,Qh5,Ng5,Bd3,Rf3,a2,b2,c2,f2,g2,h2`,
+	} {
+		NewGame(white, black).start().Evaluate()
+		expect.True(t, eval.KingDanger(Black) > danger)
+		danger = eval.KingDanger(Black)
+	}
+}
+
+// Pawn storm grows as the pawn advances toward enemy king and gets scored for
+// the storming side.
+func TestEvaluate150(t *testing.T) {
+	p := NewGame(`Kc1,g4`, `Kg8,f7,h7`).start()
+	_, storm := eval.init(p).kingCover(Black)
+	expect.Eq(t, storm, penaltyStorm[4])
+	_, storm = eval.kingCover(White)
+	expect.Eq(t, storm, 0)
+
+	p = NewGame(`Kc1,g5`, `Kg8,f7,h7`).start()
+	_, storm = eval.init(p).kingCover(Black)
+	expect.Eq(t, storm, penaltyStorm[3])
+
+	p = NewGame(`Kc1,g5`, `Kg8,f7,g6,h7`).start()
+	_, storm = eval.init(p).kingCover(Black)
+	expect.Eq(t, storm, penaltyStormBlocked[3])
+
+	engine.trace = true; defer func() { engine.trace = false }()
+	_, metrics := NewGame(`Kc1,g5`, `Kg8,f7,h7`).start().EvaluateWithTrace()
+	expect.Eq(t, metrics[`-Storm`].(Total).white, Score{penaltyStorm[3], 0})
+	expect.Eq(t, metrics[`-Storm`].(Total).black, Score{})
+}
+
+// Dead drawn material and fifty moves rule evaluate to exact draw.
+func TestEvaluate160(t *testing.T) {
+	for _, squares := range [][2]string{{`a1`, `h8`}, {`e4`, `e6`}, {`h1`, `b3`}, {`c7`, `a8`}} {
+		expect.Eq(t, NewGame(`K` + squares[0] + `,Nd4`, `K` + squares[1]).start().Evaluate(), 0)
+		expect.Eq(t, NewGame(`K` + squares[0], `M,K` + squares[1] + `,Nf5`).start().Evaluate(), 0)
+	}
+	expect.Eq(t, NewGame(`Ka1`, `Kh8`).start().Evaluate(), 0)
+	expect.Eq(t, NewGame(`Ka1,Bc1`, `Kh8`).start().Evaluate(), 0)
+	expect.Eq(t, NewGame(`Ka1,Bc1`, `Kh8,Bf8`).start().Evaluate(), 0) // Same colored bishops.
+	expect.False(t, NewGame(`Ka1,Bc1`, `Kh8,Bc8`).start().insufficientMaterial())
+	expect.False(t, NewGame(`Ka1,Nc1`, `Kh8,Nc8`).start().insufficientMaterial())
+
+	expect.Ne(t, NewGame(`4k3/8/8/8/8/8/4P3/R3K3 w - - 99 80`).start().Evaluate(), 0)
+	expect.Eq(t, NewGame(`4k3/8/8/8/8/8/4P3/R3K3 w - - 100 80`).start().Evaluate(), 0)
+}
+
+// Pawns advanced next to castled king.
+func TestEvaluate170(t *testing.T) {
+	p := NewGame(`Kb1,Qd1,Re1,a2,b2,c2,f6,g6`, `Kg8,Qd8,Re8,a7,b7,c7,f7,h7`).start()
+	expect.Eq(t, eval.init(p).pawnsNearKing(White), bonusKingPawn[5] * 2 * 3 / 2 * 2)
+	expect.Eq(t, eval.pawnsNearKing(Black), 0)
+	advanced := p.Evaluate()
+
+	p = NewGame(`Kb1,Qd1,Re1,a2,b2,c2,f2,g2`, `Kg8,Qd8,Re8,a7,b7,c7,f7,h7`).start()
+	expect.Eq(t, eval.init(p).pawnsNearKing(White), 0)
+	expect.True(t, advanced > p.Evaluate() + onePawn / 2)
+
+	p = NewGame(`Kb1,Re1,a2,b2,c2,f6,g6`, `Kg8,Re8,a7,b7,c7,f7,h7`).start()
+	expect.Eq(t, eval.init(p).pawnsNearKing(White), 0) // No mating threats without the queen.
+}
+
+// Trading queens drops the bonus for pawns near enemy king even though pawn
+// structure and kings stay the same, and the pawn cache doesn't get in the way.
+func TestEvaluate175(t *testing.T) {
+	p := NewGame(`Kg1,Qd1,a2,b2,c2,f6,g6`, `Kh8,Qd8,Rf8,a7,b7,c7,f7,h7`).start()
+	p.Evaluate()
+	p = p.makeMove(NewMoveFromNotation(p, `d1d8`))
+	p = p.makeMove(NewMoveFromNotation(p, `f8d8`))
+	warm := p.Evaluate()
+
+	fresh := NewGame(`3r3k/ppp2p1p/5PP1/8/8/8/PPP5/6K1 w - - 1 1`).start().Evaluate()
+	expect.Eq(t, warm, fresh)
+}
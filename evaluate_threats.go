@@ -21,13 +21,13 @@ func (e *Evaluation) analyzeThreats() {
 
 	threats.white = e.threats(White)
 	threats.black = e.threats(Black)
-	score.add(threats.white).sub(threats.black).apply(weightThreats)
+	score.add(threats.white).sub(threats.black).apply(weightThreats).apply(engine.activityWeight())
 	e.score.add(score)
 
 	if e.material.turf != 0 && e.material.flags & (whiteKingSafety | blackKingSafety) != 0 {
 		center.white = e.center(White)
 		center.black = e.center(Black)
-		score.clear().add(center.white).sub(center.black).apply(weightCenter)
+		score.clear().add(center.white).sub(center.black).apply(weightCenter).apply(engine.activityWeight())
 		e.score.add(score)
 	}
 }
@@ -43,7 +43,7 @@ func (e *Evaluation) threats(our int) (score Score) {
 	majors := pieces ^ p.outposts[pawn(their)]		// All pieces except king and pawns.
 
 	// Bonus for each enemy piece attacked by our pawn.
-	for bm := majors & p.pawnTargets(our, pawns); bm.any(); bm = bm.pop() {
+	for bm := majors & pawnAttackSpan(pawns, our); bm.any(); bm = bm.pop() {
 		piece := p.pieces[bm.first()]
 		score.add(bonusPawnThreat[piece.id()])
 	}
@@ -9,7 +9,6 @@
 package donna
 
 import (
-	`fmt`
 	`strings`
 	`time`
 )
@@ -19,19 +18,48 @@ type RootPv struct {
 	moves [MaxPly]Move
 }
 type Pv [MaxPly]RootPv
-type History [14][64]int
+type History [64][64]int // Butterfly history indexed by move's from and to squares.
 type Killers [MaxPly][2]Move
 
+// Aggregate search counters collected for profiling and tuning.
+type SearchStats struct {
+	Nodes          int    // Total number of nodes searched including quiescence.
+	QNodes         int    // Number of quiescence nodes searched.
+	CacheHits      int    // Number of successful cache probes.
+	CacheStores    int    // Number of cache entries written.
+	CacheCutoffs   int    // Number of nodes cut off by cached score.
+	NullCutoffs    int    // Number of null move cutoffs.
+	Researches     int    // Number of late move reduction re-searches.
+	PvResearches   int    // Number of full window re-searches at PV nodes after zero window fails high.
+	FutilityPrunes int    // Number of futility and delta pruned nodes or moves.
+	TablebaseHits  int    // Number of successful tablebase probes.
+	BetaCutoffs    int    // Number of beta cutoffs.
+	Cutoffs        [8]int // Beta cutoffs by move index, last entry is 8th move and up.
+}
+
 type Game struct {
 	nodes       int 	// Number of regular nodes searched.
 	qnodes      int 	// Number of quiescence nodes searched.
+	researches  int 	// Number of aspiration window re-searches.
+	score       int 	// Best root score of the last search.
+	badMoves    int 	// Consecutive moves with the score below resign threshold.
+	selDepth    int 	// Deepest ply reached including quiescence.
+	stats       SearchStats // Search statistics.
+	started     time.Time 	// Time when the search has started.
+	reported    int64 	// Search time of the last progress report in milliseconds.
 	token       uint8 	// Cache's expiration token.
 	deepening   bool 	// True when searching first root move.
 	improving   bool 	// True when root search score is not falling.
+	verifying   bool 	// True when verifying null move cutoff.
 	volatility  float32 	// Root search stability count.
 	initial     string   	// Initial position (FEN or algebraic).
+	firstMove   int 	// Full move number of the initial position.
 	history     History  	// Good moves history.
 	killers     Killers  	// Killer moves.
+	refutations []RootPv 	// Refutation lines for root moves.
+	excluded    []Move 	// Root moves to skip when searching for extra lines.
+	multipv     []RootPv 	// Extra principal variations when MultiPV > 1.
+	multiscore  []int 	// Scores of the extra principal variations.
 	rootpv      RootPv 	// Principal variation for root moves.
 	pv          Pv  	// Principal variations for each ply.
 	cache       Cache 	// Transposition table.
@@ -48,7 +76,8 @@ var game Game
 // The second option is a bit less pricise (ex. no en-passant square) but it is
 // much more useful when writing tests from memory.
 func NewGame(args ...string) *Game {
-	game = Game{ cache: NewCache(engine.cacheSize), pawnCache: PawnCache{} }
+	game = Game{ cache: NewCache(engine.cacheSize) }
+	game.resizePawnCache(engine.pawnCacheSize)
 
 	switch len(args) {
 	case 0: // Initial position.
@@ -63,8 +92,9 @@ func NewGame(args ...string) *Game {
 }
 
 func (game *Game) start() *Position {
-	engine.clock.halt = false
+	engine.clock.halt.Store(false)
 	tree, node, rootNode = [1024]Position{}, 0, 0
+	game.firstMove = 1
 
 	// Was the game started with FEN or algebraic notation?
 	sides := strings.Split(game.initial, ` : `)
@@ -74,21 +104,59 @@ func (game *Game) start() *Position {
 	return NewPositionFromFEN(game, game.initial)
 }
 
+// Restarts the game from the given FEN. If the FEN is not valid the current
+// position stays intact and nil gets returned.
+func (game *Game) restart(fen string) *Position {
+	current := tree[node]
+	if NewPositionFromFEN(game, fen) == nil {
+		tree[node] = current
+		return nil
+	}
+	game.initial = fen
+
+	return game.start()
+}
+
 func (game *Game) position() *Position {
 	return &tree[node]
 }
 
-// Resets principal variation as well as killer moves and move history. Cache
+// Returns game phase along with its label for the position at the start of the
+// given move number as counted from the full move number of the initial FEN
+// position (1 if not set). Move numbers out of range get clamped to the game's
+// move history.
+func (game *Game) PhaseAt(moveNumber int) (phase int, label string) {
+	ply := (moveNumber - game.firstMove) * 2 - let(tree[0].color == Black, 1, 0)
+	ply = max(0, min(ply, node))
+	phase = tree[ply].Phase()
+
+	switch {
+	case phase > phaseOpening:
+		label = `opening`
+	case phase < phaseEndgame:
+		label = `endgame`
+	default:
+		label = `middlegame`
+	}
+
+	return phase, label
+}
+
+// Resets principal variation and killer moves, and ages move history. Cache
 // entries get expired by incrementing cache token. Root node gets set to the
 // current tree node to match the position.
 func (game *Game) getReady() *Game {
 	game.rootpv = RootPv{}
+	game.multipv, game.multiscore = game.multipv[:0], game.multiscore[:0]
 	game.pv = Pv{}
 	game.killers = Killers{}
-	game.history = History{}
+	game.resetHistory()
 	game.deepening = false
+	game.verifying = false
 	game.improving = true
 	game.volatility = 0.0
+	game.researches = 0
+	game.selDepth = 0
 	game.token += 4 // <-- Wraps around: ...248, 252, 0, 4... reserving last 2 bits.
 
 	rootNode = node
@@ -108,24 +176,50 @@ func updateRootPv() {
 func (game *Game) Think() Move {
 	start := time.Now()
 	position := game.position()
+	game.started, game.reported = start, 0
 	game.nodes, game.qnodes = 0, 0
+	game.stats = SearchStats{}
+	engine.publish(Move(0), 0, 0)
 
-	if len(engine.bookFile) != 0 {
+	if engine.options.mateIn > 0 {
+		return game.thinkMate(position, start)
+	}
+
+	if len(engine.bookFile) != 0 && !engine.noBook {
 		if book, err := NewBook(engine.bookFile); err == nil {
 			if move := book.pickMove(position); move != 0 {
 				game.printBestMove(move, since(start))
 				return move
 			}
-		} else if !engine.uci {
-			fmt.Printf("Book error: %v\n", err)
+		} else if !engine.uci && !engine.xboard {
+			engine.reply("Book error: %v\n", err)
+		}
+	}
+
+	// Don't bother searching if there is only one legal move.
+	if gen := NewRootGen(position, 1).generateAllMoves().validOnly(); gen.size() == 1 {
+		move := gen.nextMove()
+		if engine.uci {
+			engine.reply("info string only move %s\n", move.notation())
+		}
+		game.printBestMove(move, since(start))
+		return move
+	}
+
+	// Play tablebase move right away if the position is covered.
+	if move := game.tablebaseMove(position); move.some() {
+		if engine.uci {
+			engine.reply("info string tablebase move %s\n", move.notation())
 		}
+		game.printBestMove(move, since(start))
+		return move
 	}
 
 	game.getReady()
 	score, move, status, alpha, beta := 0, Move(0), InProgress, -Checkmate, Checkmate
 
-	if !engine.uci {
-		fmt.Println(ansiWhite + `Depth   Time     Nodes    QNodes   Nodes/s   Cache    Score   Best` + ansiNone)
+	if !engine.uci && !engine.xboard {
+		engine.reply(ansiWhite + "Depth   Time     Nodes    QNodes   Nodes/s   Cache    Score   Best" + ansiNone + "\n")
 	}
 
 	if !engine.fixedDepth() {
@@ -139,6 +233,14 @@ func (game *Game) Think() Move {
 		// Assume volatility decreases with each new iteration.
 		game.volatility /= 2.0
 
+		// Optionally start each iteration with no killer moves. Killers
+		// carried over from previous iteration help move ordering, so
+		// clearing them costs a bit of strength in exchange for search
+		// being more reproducible.
+		if engine.options.clearKillers {
+			game.killers = Killers{}
+		}
+
 		// At low depths do the search with full alpha/beta spread.
 		// Aspiration window searches kick in at depth 5 and up.
 		if depth < 5 {
@@ -148,7 +250,7 @@ func (game *Game) Think() Move {
 				updateRootPv()
 			}
 		} else {
-			aspiration := onePawn / 3
+			aspiration, growth := engine.aspirationWindow()
 			alpha = max(score - aspiration, -Checkmate)
 			beta = min(score + aspiration, Checkmate)
 
@@ -162,7 +264,7 @@ func (game *Game) Think() Move {
 					updateRootPv()
 				}
 
-				if !engine.fixedDepth() && engine.clock.halt {
+				if !engine.fixedDepth() && engine.halted() {
 					break
 				}
 
@@ -175,34 +277,236 @@ func (game *Game) Think() Move {
 					break;
 				}
 
-				aspiration *= 2
+				game.researches++
+				aspiration *= growth
 			}
 			// TBD: position.cache(game.rootpv[0], score, 0, 0)
 		}
-		if engine.clock.halt {
+		if engine.halted() {
 			score = bestScore
 		}
 
-		move = game.rootpv.moves[0]
+		move, game.score = game.rootpv.moves[0], score
+		engine.publish(move, score, depth)
 		status = position.status(move, score)
 		game.printPrincipal(depth, score, status, since(start))
+		if engine.uci && engine.refutations {
+			engine.uciRefutations(move)
+		}
+		if engine.pvLines() > 1 && !engine.halted() {
+			game.searchMultiPv(depth, start)
+		}
+	}
+
+	if engine.limitStrength {
+		move = game.weakerMove(move)
+	} else if engine.tieBreak != tieBreakNone {
+		move = game.tieBreakMove(move)
 	}
 
+	game.adjudicate(game.score)
 	game.printBestMove(move, since(start))
 
 	return move
 }
 
+// Finds and reports extra principal variations in MultiPV mode. Each line
+// is searched with full window while excluding the root moves of the lines
+// found so far. The root move list is saved and restored afterwards so that
+// the next iteration starts with the same move ordering as in single line
+// mode.
+func (game *Game) searchMultiPv(depth int, start time.Time) {
+	position, root := game.position(), moveList[0]
+	lines := min(engine.pvLines(), root.size())
+
+	game.excluded = append(game.excluded[:0], game.rootpv.moves[0])
+	game.multipv, game.multiscore = game.multipv[:0], game.multiscore[:0]
+	for k := 2; k <= lines; k++ {
+		score := position.search(-Checkmate, Checkmate, depth)
+		if engine.halted() || game.pv[0].size == 0 {
+			break
+		}
+
+		line := RootPv{ size: game.pv[0].size }
+		copy(line.moves[0:], game.pv[0].moves[0:line.size])
+		game.multipv = append(game.multipv, line)
+		game.multiscore = append(game.multiscore, score)
+		game.excluded = append(game.excluded, line.moves[0])
+		if engine.uci && k <= engine.multiPV {
+			engine.uciLine(k, depth, score, since(start), line)
+		}
+	}
+
+	game.excluded = game.excluded[:0]
+	moveList[0] = root
+}
+
+// Picks the move to play at reduced strength. Each line found by the last
+// search iteration gets random noise added to its score, and the move with
+// the highest resulting score wins. Seeded random number generator keeps
+// the choice reproducible.
+func (game *Game) weakerMove(best Move) Move {
+	_, noise := engine.skill()
+	if noise == 0 || len(game.multipv) == 0 {
+		return best
+	}
+
+	move, top := best, game.score + random.Intn(noise + 1)
+	for i, line := range game.multipv {
+		if score := game.multiscore[i] + random.Intn(noise + 1); score > top {
+			move, top = line.moves[0], score
+		}
+	}
+
+	return move
+}
+
+// Picks the move to play according to tie-break policy among the lines that
+// score within tieBreakMargin of the best one. The lines are tried in score
+// order so the best move wins if it already suits the policy.
+func (game *Game) tieBreakMove(best Move) Move {
+	position := game.position()
+
+	candidates := []Move{ best }
+	for i, line := range game.multipv {
+		if game.multiscore[i] >= game.score - tieBreakMargin {
+			candidates = append(candidates, line.moves[0])
+		}
+	}
+
+	for _, move := range candidates {
+		next := position.makeMove(move)
+		check, tactical := next.isInCheck(next.color), next.IsTactical()
+		next.undoLastMove()
+
+		switch engine.tieBreak {
+		case tieBreakTactical:
+			if !move.isQuiet() || check {
+				return move
+			}
+		case tieBreakPositional:
+			if move.isQuiet() && !tactical {
+				return move
+			}
+		}
+	}
+
+	return best
+}
+
+// Returns true if the root move should be skipped in MultiPV search.
+func (game *Game) isExcluded(move Move) bool {
+	for _, excluded := range game.excluded {
+		if move == excluded {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Counts consecutive search scores below the resign threshold and signals the
+// resignation once their number reaches the limit. UCI has no resign command
+// so we send `info string resign` instead whereas XBoard gets genuine `resign`.
+// Returns true when resigning.
+func (game *Game) adjudicate(score int) bool {
+	if engine.resign <= 0 || engine.resignMoves <= 0 {
+		return false
+	}
+
+	if score > -engine.resign {
+		game.badMoves = 0
+		return false
+	}
+
+	game.badMoves++
+	if game.badMoves != engine.resignMoves {
+		return false
+	}
+
+	if engine.uci {
+		engine.reply("info string resign\n")
+	} else if engine.xboard {
+		engine.reply("resign\n")
+	}
+	if engine.onResign != nil {
+		engine.onResign()
+	}
+
+	return true
+}
+
+// Search limits for convenience search methods. Zero values mean no limit.
+type SearchLimits struct {
+	Depth    int   // Search X plies only.
+	MoveTime int64 // Search X milliseconds.
+}
+
+// Searches current game position within given limits and returns the best
+// line in standard algebraic notation along with its score for the side to
+// move. The line is converted by playing out the moves on a scratch position.
+func (game *Game) BestLineSAN(limits SearchLimits) ([]string, int) {
+	options := engine.options; defer func() { engine.options = options }()
+	engine.options = Options{ maxDepth: limits.Depth, moveTime: limits.MoveTime }
+
+	game.score = 0
+	move, pv := game.Think(), game.rootpv
+	if pv.size == 0 || pv.moves[0] != move { // Book or only move.
+		pv = RootPv{ size: let(move.some(), 1, 0) }
+		pv.moves[0] = move
+	}
+
+	line, p := []string{}, game.position()
+	for i := 0; i < pv.size; i++ {
+		line = append(line, pv.moves[i].san(p))
+		p = p.makeMove(pv.moves[i])
+	}
+	for i := 0; i < len(line); i++ {
+		p = p.undoLastMove()
+	}
+
+	return line, game.score
+}
+
+// Same as Think() but also returns search statistics.
+func (game *Game) ThinkWithStats() (Move, SearchStats) {
+	move := game.Think()
+	game.stats.Nodes, game.stats.QNodes = game.nodes + game.qnodes, game.qnodes
+
+	return move, game.stats
+}
+
+// Reports search time, speed, and cache usage about every 500ms so that the
+// GUI keeps getting updates during long iterations.
+func (game *Game) reportProgress() {
+	if elapsed := since(game.started); elapsed - game.reported >= 500 {
+		game.reported = elapsed
+		engine.uciStats(elapsed)
+	}
+}
+
+// Records beta cutoff produced by the move with the given index (1-based).
+func (game *Game) saveCutoff(moveCount int) {
+	game.stats.BetaCutoffs++
+	game.stats.Cutoffs[min(moveCount, len(game.stats.Cutoffs)) - 1]++
+}
+
 // When in doubt, do what the President does ―- guess.
 func (game *Game) keepThinking(depth, status int, move Move) bool {
 	if depth == 1 || depth > MaxDepth || status != InProgress {
 		return depth == 1
 	}
 
+	if limit, _ := engine.skill(); depth > limit {
+		return false
+	}
+
 	if engine.fixedDepth() {
 		return depth <= engine.options.maxDepth
-	} else if engine.clock.halt {
+	} else if engine.halted() {
 		return false
+	} else if engine.pondering() {
+		return true
 	}
 
 	// Stop deepening if it's the only move.
@@ -230,6 +534,8 @@ func (game *Game) keepThinking(depth, status int, move Move) bool {
 func (game *Game) printBestMove(move Move, duration int64) {
 	if engine.uci {
 		engine.uciBestMove(move, duration)
+	} else if engine.xboard {
+		engine.xboardMove(move)
 	} else {
 		engine.replBestMove(move)
 	}
@@ -237,10 +543,12 @@ func (game *Game) printBestMove(move Move, duration int64) {
 
 // Prints principal variation. Note that in REPL advantage white is always +score
 // and advantage black is -score whereas in UCI +score is advantage current side
-// and -score is advantage opponent.
+// and -score is advantage opponent (same goes for XBoard).
 func (game *Game) printPrincipal(depth, score, status int, duration int64) {
 	if engine.uci {
 		engine.uciPrincipal(depth, score, duration)
+	} else if engine.xboard {
+		engine.xboardPrincipal(depth, score, duration)
 	} else {
 		if game.position().color == Black {
 			score = -score
@@ -262,13 +570,37 @@ func (game *Game) saveBest(ply int, move Move) *Game {
 	return game
 }
 
+// Saves root move followed by the opponent's best reply line.
+func (game *Game) saveRefutation(move Move) *Game {
+	line := RootPv{ size: 1 }
+	line.moves[0] = move
+	if size := game.pv[1].size; size > 1 {
+		copy(line.moves[1:], game.pv[1].moves[1:size])
+		line.size = size
+	}
+	game.refutations = append(game.refutations, line)
+
+	return game
+}
+
+// Returns refutation line for the given root move, if any.
+func (game *Game) refutation(move Move) RootPv {
+	for _, line := range game.refutations {
+		if line.moves[0] == move {
+			return line
+		}
+	}
+
+	return RootPv{}
+}
+
 func (game *Game) saveGood(depth int, move Move) *Game {
 	if move.isQuiet() {
 		if ply := ply(); move != game.killers[ply][0] {
 			game.killers[ply][1] = game.killers[ply][0]
 			game.killers[ply][0] = move
 		}
-		game.history[move.piece()][move.to()] += depth * depth
+		game.history[move.from()][move.to()] += depth * depth
 	}
 
 	return game
@@ -280,7 +612,19 @@ func (game *Game) updatePoor(depth int, bestMove Move, mgen *MoveGen) *Game {
 
 	for move := mgen.nextMove(); move != 0; move = mgen.nextMove() {
 		if move.isQuiet() {
-			game.history[move.piece()][move.to()] = let(move == bestMove, value, -value)
+			game.history[move.from()][move.to()] = let(move == bestMove, value, -value)
+		}
+	}
+
+	return game
+}
+
+// Ages history scores left from the previous search rather than clearing
+// them, so that the next search starts with somewhat reasonable move order.
+func (game *Game) resetHistory() *Game {
+	for from := range game.history {
+		for to := range game.history[from] {
+			game.history[from][to] /= 4
 		}
 	}
 
@@ -290,7 +634,7 @@ func (game *Game) updatePoor(depth int, bestMove Move, mgen *MoveGen) *Game {
 // Checks whether the move is among good moves captured so far and returns its
 // history value.
 func (game *Game) good(move Move) int {
-	return game.history[move.piece()][move.to()]
+	return game.history[move.from()][move.to()]
 }
 
 func (game *Game) String() string {
@@ -8,6 +8,11 @@
 
 package donna
 
+import (
+	`math/rand`
+	`sort`
+)
+
 type MoveWithScore struct {
 	move  Move
 	score int
@@ -113,6 +118,12 @@ func (gen *MoveGen) amongValid(someMove Move) bool {
 	return false
 }
 
+// Returns true if the last move returned by the gen.nextMove() was ranked as
+// the capture that loses material.
+func (gen *MoveGen) losing() bool {
+	return gen.list[gen.head - 1].score < 0
+}
+
 // Assigns given score to the last move returned by the gen.nextMove().
 func (gen *MoveGen) scoreMove(depth, score int) *MoveGen {
 	current := &gen.list[gen.head - 1]
@@ -166,7 +177,13 @@ func (gen *MoveGen) rank(bestMove Move) *MoveGen {
 		if move == bestMove {
 			gen.list[i].score = 0xFFFF
 		} else if !move.isQuiet() || move.isEnpassant() {
-			gen.list[i].score = 8192 + move.value()
+			// Captures that lose material as per static exchange
+			// evaluation go after quiet moves.
+			if move.capture().some() && gen.p.exchange(move) < 0 {
+				gen.list[i].score = -8192 + move.value()
+			} else {
+				gen.list[i].score = 8192 + move.value()
+			}
 		} else if move == game.killers[gen.ply][0] {
 			gen.list[i].score = 4096
 		} else if move == game.killers[gen.ply][1] {
@@ -179,6 +196,49 @@ func (gen *MoveGen) rank(bestMove Move) *MoveGen {
 	return gen.sort()
 }
 
+// Shuffles remaining moves in random order using the given seed. This is used
+// in tests to verify that search results don't depend on move ordering.
+func (gen *MoveGen) shuffle(seed int64) *MoveGen {
+	rand.New(rand.NewSource(seed)).Shuffle(gen.tail - gen.head, func(i, j int) {
+		gen.list[gen.head + i], gen.list[gen.head + j] = gen.list[gen.head + j], gen.list[gen.head + i]
+	})
+
+	return gen
+}
+
+// Sorts the moves in place in descending order of scores returned by the
+// scorer. Moves with equal scores keep their original order.
+func SortMoves(moves []Move, scorer func(Move) int) []Move {
+	list := make([]MoveWithScore, len(moves))
+	for i, move := range moves {
+		list[i] = MoveWithScore{ move, scorer(move) }
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].score > list[j].score
+	})
+	for i := range list {
+		moves[i] = list[i].move
+	}
+
+	return moves
+}
+
+// Returns default move scorer that reproduces engine's move ordering: captures
+// by most valueable victim/least valueable attacker come first followed by
+// quiet moves ranked by history, and captures losing material as per static
+// exchange evaluation come last.
+func (p *Position) MoveScorer() func(Move) int {
+	return func(move Move) int {
+		if !move.isQuiet() || move.isEnpassant() {
+			if move.capture().some() && p.exchange(move) < 0 {
+				return -8192 + move.value()
+			}
+			return 8192 + move.value()
+		}
+		return max(-4096, min(4096, game.good(move)))
+	}
+}
+
 func (gen *MoveGen) quickRank() *MoveGen {
 	if gen.size() < 2 {
 		return gen
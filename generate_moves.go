@@ -13,6 +13,9 @@ func (gen *MoveGen) generateRootMoves() *MoveGen {
 
 	if !gen.onlyMove() {
 		gen.validOnly().rank(Move(0))
+		if engine.shuffle != 0 {
+			gen.shuffle(engine.shuffle)
+		}
 	}
 
 	return gen
@@ -70,10 +73,10 @@ func (gen *MoveGen) kingMoves(color int) *MoveGen {
 
 		kingside, queenside := gen.p.canCastle(color)
 		if kingside {
-			gen.moveKing(square, bit[G1 + 56 * color])
+			gen.add(NewCastle(gen.p, square, G1 + 56 * color))
 		}
 		if queenside {
-			gen.moveKing(square, bit[C1 + 56 * color])
+			gen.add(NewCastle(gen.p, square, C1 + 56 * color))
 		}
 	}
 
@@ -28,10 +28,10 @@ func (gen *MoveGen) generateQuiets() *MoveGen {
 		home := homeKing[color]
 		kingside, queenside := p.canCastle(color)
 		if kingside {
-			gen.addQuiet(NewCastle(p, home, home + 2))
+			gen.addQuiet(NewCastle(p, home, G1 + color * A8))
 		}
 		if queenside {
-			gen.addQuiet(NewCastle(p, home, home - 2))
+			gen.addQuiet(NewCastle(p, home, C1 + color * A8))
 		}
 	}
 
@@ -18,7 +18,7 @@ func TestGenerate010(t *testing.T) {
 	expect.Eq(t, gen.allMoves(), `[a2-a3 a2-a4 d2-d3 d2-d4 b3-b4 h3-h4 c4-c5 g4-g5 f5-f6 e6-e7 Ka1-b1 Ka1-b2]`)
 }
 
-// LVA/MVV capture ordering.
+// LVA/MVV capture ordering, captures losing material go last.
 func TestGenerate110(t *testing.T) {
 	game := NewGame(`Kd4,e4,Nf4,Bc4,Ra5,Qh5`, `Kd8,Qd5`)
 	gen := NewMoveGen(game.start()).generateCaptures().rank(Move(0))
@@ -30,28 +30,43 @@ func TestGenerate120(t *testing.T) {
 	game := NewGame(`Kd4,e4,Nf4,Bc4,Ra5,Qh5`, `Kd8,Qd5,Rf5`)
 	gen := NewMoveGen(game.start()).generateCaptures().rank(Move(0))
 
-	expect.Eq(t, gen.allMoves(), `[e4xd5 Nf4xd5 Bc4xd5 Ra5xd5 Kd4xd5 e4xf5 Qh5xf5]`)
+	expect.Eq(t, gen.allMoves(), `[e4xd5 Nf4xd5 Bc4xd5 Ra5xd5 e4xf5 Qh5xf5 Kd4xd5]`)
 }
 
 func TestGenerate130(t *testing.T) {
 	game := NewGame(`Kd4,e4,Nf4,Bc4,Ra5,Qh5`, `Kd8,Qd5,Rf5,Bg6`)
 	gen := NewMoveGen(game.start()).generateCaptures().rank(Move(0))
 
-	expect.Eq(t, gen.allMoves(), `[e4xd5 Nf4xd5 Bc4xd5 Ra5xd5 Kd4xd5 e4xf5 Qh5xf5 Nf4xg6 Qh5xg6]`)
+	expect.Eq(t, gen.allMoves(), `[e4xd5 Nf4xd5 Bc4xd5 Ra5xd5 e4xf5 Nf4xg6 Qh5xg6 Kd4xd5 Qh5xf5]`)
 }
 
 func TestGenerate140(t *testing.T) {
 	game := NewGame(`Kd4,e4,Nf4,Bc4,Ra5,Qh5`, `Kd8,Qd5,Rf5,Bg6,Nh3`)
 	gen := NewMoveGen(game.start()).generateCaptures().rank(Move(0))
 
-	expect.Eq(t, gen.allMoves(), `[e4xd5 Nf4xd5 Bc4xd5 Ra5xd5 Kd4xd5 e4xf5 Qh5xf5 Nf4xg6 Qh5xg6 Nf4xh3 Qh5xh3]`)
+	expect.Eq(t, gen.allMoves(), `[e4xd5 Nf4xd5 Bc4xd5 Ra5xd5 e4xf5 Nf4xg6 Qh5xg6 Nf4xh3 Qh5xh3 Kd4xd5 Qh5xf5]`)
 }
 
 func TestGenerate150(t *testing.T) {
 	game := NewGame(`Kd4,e4,Nf4,Bc4,Ra5,Qh5`, `Kd8,Qd5,Rf5,Bg6,Nh3,e2`)
 	gen := NewMoveGen(game.start()).generateCaptures().rank(Move(0))
 
-	expect.Eq(t, gen.allMoves(), `[e4xd5 Nf4xd5 Bc4xd5 Ra5xd5 Kd4xd5 e4xf5 Qh5xf5 Nf4xg6 Qh5xg6 Nf4xh3 Qh5xh3 Nf4xe2 Bc4xe2 Qh5xe2]`)
+	expect.Eq(t, gen.allMoves(), `[e4xd5 Nf4xd5 Bc4xd5 Ra5xd5 e4xf5 Nf4xg6 Qh5xg6 Nf4xh3 Qh5xh3 Nf4xe2 Bc4xe2 Qh5xe2 Kd4xd5 Qh5xf5]`)
+}
+
+// Ranking flags captures that lose material so that quiescence search doesn't
+// have to run static exchange evaluation again.
+func TestGenerate160(t *testing.T) {
+	game := NewGame(`Kd4,e4,Nf4,Bc4,Ra5,Qh5`, `Kd8,Qd5,Rf5,Bg6`)
+	gen := NewMoveGen(game.start()).generateCaptures().rank(Move(0))
+
+	losing := []string{}
+	for move := gen.nextMove(); move.some(); move = gen.nextMove() {
+		if gen.losing() {
+			losing = append(losing, move.str())
+		}
+	}
+	expect.Eq(t, losing, []string{`Kd4xd5`, `Qh5xf5`})
 }
 
 // Vaditaing generated moves.
@@ -72,3 +87,17 @@ func TestGenerate210(t *testing.T) {
 	black := NewMoveGen(p).generateMoves().validOnly()
 	expect.Eq(t, black.allMoves(), `[Ka8-a7 Ka8-b7 Ka8-b8]`)
 }
+
+// Sorting moves with default scorer.
+func TestGenerate300(t *testing.T) {
+	p := NewGame(`Ke1,Qd1,Nc3,e4`, `Ke8,Qb5,Rd8,d5`).start()
+	moves := []Move{
+		NewMove(p, D1, D2), // Quiet.
+		NewMove(p, D1, D5), // Qd1xd5 Rd8xd5 loses the queen.
+		NewMove(p, E1, E2), // Quiet.
+		NewMove(p, E4, D5), // Wins the pawn.
+		NewMove(p, C3, B5), // Wins the queen.
+	}
+	SortMoves(moves, p.MoveScorer())
+	expect.Eq(t, moves, `[Nc3xb5 e4xd5 Qd1-d2 Ke1-e2 Qd1xd5]`)
+}
@@ -199,7 +199,7 @@ func initPST() {
 
 		// White pieces: flip square index since bonus points have been
 		// set up from black's point of view.
-		flip := square ^ A8
+		flip := Flip(square)
 		pst[Pawn]  [square].add(Score{bonusPawn  [0][flip], bonusPawn  [1][flip]}).add(valuePawn)
 		pst[Knight][square].add(Score{bonusKnight[0][flip], bonusKnight[1][flip]}).add(valueKnight)
 		pst[Bishop][square].add(Score{bonusBishop[0][flip], bonusBishop[1][flip]}).add(valueBishop)
@@ -259,6 +259,16 @@ func initMaterial() {
 			materialBase[index].score.midgame += adjustment
 			materialBase[index].score.endgame += adjustment
 		}
+
+		// Rook vs. two minor pieces: the minors do better in the middlegame
+		// while the rook gets stronger as the board simplifies.
+		if wQ == bQ {
+			if wR - bR == 1 && (bN + bB) - (wN + wB) == 2 {
+				materialBase[index].score.add(rookVsMinors)
+			} else if bR - wR == 1 && (wN + wB) - (bN + bB) == 2 {
+				materialBase[index].score.sub(rookVsMinors)
+			}
+		}
 										}
 									}
 								}
@@ -350,6 +360,11 @@ func endgames(wP, wN, wB, wR, wQ, bP, bN, bB, bR, bQ int) (flags uint8, endgame
 		flags |= lesserKnownEndgame
 		endgame = (*Evaluation).kingAndPawnVsKingAndPawn
 
+	// Lesser known endgame: kings and pawns only, with pawns on both sides.
+	} else if allMinor + allMajor == 0 && wP > 0 && bP > 0 {
+		flags |= lesserKnownEndgame
+		endgame = (*Evaluation).kingAndPawnsVsKingAndPawns
+
 	// Lesser known endgame: bishop and pawn vs. bare king.
 	} else if bareKing && allMajor == 0 && wN + bN == 0 && (wB * wP == 1 || bB * bP == 1) {
 		flags |= lesserKnownEndgame
@@ -370,6 +385,16 @@ func endgames(wP, wN, wB, wR, wQ, bP, bN, bB, bR, bQ int) (flags uint8, endgame
 		flags |= lesserKnownEndgame
 		endgame = (*Evaluation).lastPawnLeft
 
+	// Lesser known endgame: equal heavy pieces with pawns on both sides.
+	} else if allMinor == 0 && allMajor > 0 && wR == bR && wQ == bQ && wP > 0 && bP > 0 {
+		flags |= lesserKnownEndgame
+		endgame = (*Evaluation).blockedPawnWall
+
+	// Lesser known endgame: bishop vs. knight with pawns.
+	} else if allMajor == 0 && ((wB == 1 && wN == 0 && bB == 0 && bN == 1) || (wB == 0 && wN == 1 && bB == 1 && bN == 0)) {
+		flags |= lesserKnownEndgame
+		endgame = (*Evaluation).bishopVsKnight
+
 	// Check for potential opposite-colored bishops.
 	} else if wB * bB == 1 {
 		flags |= singleBishops
@@ -238,3 +238,18 @@ func TestMaterial150(t *testing.T) {
 	p := NewGame(`Ke1,Bc1,a2,b2,c2,d4`, `Ke8,Bf8,f7,g7,h7`).start()
 	expect.Eq(t, p.balance, balance)
 }
+
+// Rook vs. two minors: minors are better with many pieces on the board and the
+// rook improves as the board simplifies.
+func TestMaterial200(t *testing.T) {
+	heavy := NewGame(`Ke1,Qd1,Ra1,Rh1,Bc1,Nb1,a2,h2`, `Ke8,Qd8,Ra8,Bc8,Bf8,Nb8,Ng8,a7,h7`).start().balance
+	light := NewGame(`Ke1,Rh1,a2,h2`, `Ke8,Bc8,Nb8,a7,h7`).start().balance
+
+	expect.True(t, rookVsMinors.blended(materialBase[heavy].phase) < 0)
+	expect.True(t, rookVsMinors.blended(materialBase[light].phase) > 0)
+
+	// Adjustment is symmetric for both sides.
+	mirror := NewGame(`Ke1,Qd1,Ra1,Bc1,Bf1,Nb1,Ng1,a2,h2`, `Ke8,Qd8,Ra8,Rh8,Bc8,Nb8,a7,h7`).start().balance
+	expect.Eq(t, materialBase[mirror].score.midgame, -materialBase[heavy].score.midgame)
+	expect.Eq(t, materialBase[mirror].score.endgame, -materialBase[heavy].score.endgame)
+}
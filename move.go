@@ -74,9 +74,16 @@ func NewMoveFromNotation(p *Position, e2e4 string) Move {
 	from := square(int(e2e4[1] - '1'), int(e2e4[0] - 'a'))
 	to := square(int(e2e4[3] - '1'), int(e2e4[2] - 'a'))
 
-	// Check if this is a castle.
-	if p.pieces[from].isKing() && abs(from - to) == 2 {
-		return NewCastle(p, from, to)
+	// Check if this is a castle. In Chess960 mode the castle is encoded as
+	// the king capturing its own rook.
+	if piece := p.pieces[from]; piece.isKing() {
+		if engine.chess960 {
+			if color := piece.color(); p.pieces[to] == rook(color) {
+				return NewCastle(p, from, let(to > from, G1, C1) + color * A8)
+			}
+		} else if abs(from - to) == 2 {
+			return NewCastle(p, from, to)
+		}
 	}
 
 	// Special handling for pawn pushes because they might cause en-passant
@@ -217,6 +224,12 @@ func (m Move) isCastle() bool {
 	return m & isCastle != 0
 }
 
+// Returns castle rook index for the castle move: 0 for kingside castle, and
+// 1 for queenside.
+func (m Move) castleSide() int {
+	return let(col(m.to()) == 6, 0, 1)
+}
+
 func (m Move) isCapture() bool {
 	return m & isCapture != 0
 }
@@ -282,10 +295,24 @@ func (m Move) legit(p *Position, pins Bitmask) bool {
 	from, to, piece, capture := m.split()
 
 	// `from` must have a piece and `to` can't have a piece of the same color.
-	if p.outposts[piece].off(from) || p.outposts[color].on(to) {
+	// In Chess960 the castling king might end up on the square occupied by
+	// the castle rook or stay where it is.
+	if p.outposts[piece].off(from) || (p.outposts[color].on(to) && !m.isCastle()) {
 		return false
 	}
 
+	// Check castles before the target square gets verified.
+	if m.isCastle() {
+		if !piece.isKing() || from != homeKing[color] || capture.some() {
+			return false
+		}
+		kingside, queenside := p.canCastle(color)
+		if m.castleSide() == 0 {
+			return kingside && to == G1 + color * A8
+		}
+		return queenside && to == C1 + color * A8
+	}
+
 	// First check pawn captures and pushes.
 	if piece.isPawn() {
 		if p.enpassant != 0 {
@@ -318,34 +345,11 @@ func (m Move) legit(p *Position, pins Bitmask) bool {
 		return false
 	}
 
-	// Now check king moves including castles.
+	// Now check king moves.
 	if piece.isKing() {
-		if m.isCastle() {
-			if from != homeKing[color] {
-				return false
-			}
-			switch to {
-			case G1, G8:
-				if p.outposts[rook(color)].off(to + 1) || (p.castles & castleKingside[color] == 0) || (gapKing[color] & p.board).any() {
-					return false
-				}
-				return (castleKing[color] & p.allAttacks(color^1)).empty()
-			case C1, C8:
-				if p.outposts[rook(color)].off(to - 2) || (p.castles & castleQueenside[color] == 0) || (gapQueen[color] & p.board).any() {
-					return false
-				}
-				return (castleQueen[color] & p.allAttacks(color^1)).empty()
-			}
-			return false
-		}
 		return p.kingAttacksAt(from, color).on(to)
 	}
 
-	// Anything castle-related is now non-legit.
-	if m.isCastle() {
-		return false
-	}
-
 	// Check remaining pieces.
 	switch piece.kind() {
 	case Knight:
@@ -367,6 +371,9 @@ func (m Move) notation() string {
 	var buffer bytes.Buffer
 
 	from, to, _, _ := m.split()
+	if engine.chess960 && m.isCastle() { // King captures own rook.
+		to = castleRook[m.color()][m.castleSide()]
+	}
 	buffer.WriteByte(byte(col(from)) + 'a')
 	buffer.WriteByte(byte(row(from)) + '1')
 	buffer.WriteByte(byte(col(to)) + 'a')
@@ -378,6 +385,75 @@ func (m Move) notation() string {
 	return buffer.String()
 }
 
+// Returns string representation of the move in standard algebraic notation
+// for the given position, ex. `Nf3`, `exd5`, `Rad1`, `e8=Q+` or `Qh7#`. The
+// position must be the one the move is about to be made in.
+func (m Move) san(p *Position) string {
+	var buffer bytes.Buffer
+
+	from, to, piece, capture := m.split()
+	if m.isCastle() {
+		if m.castleSide() == 0 {
+			buffer.WriteString(`O-O`)
+		} else {
+			buffer.WriteString(`O-O-O`)
+		}
+	} else if piece.isPawn() {
+		if capture.some() {
+			buffer.WriteByte(byte(col(from)) + 'a')
+			buffer.WriteByte('x')
+		}
+		buffer.WriteByte(byte(col(to)) + 'a')
+		buffer.WriteByte(byte(row(to)) + '1')
+		if promo := m.promo(); promo.some() {
+			buffer.WriteByte('=')
+			buffer.WriteByte(promo.char())
+		}
+	} else {
+		buffer.WriteByte(piece.char())
+
+		// Disambiguate if another piece of the same kind can move to the
+		// same square: use the file if possible, then the rank, or both.
+		ambiguous, sameCol, sameRow := false, false, false
+		gen := NewGen(p, MaxPly).generateAllMoves()
+		for move := gen.nextMove(); move.some(); move = gen.nextMove() {
+			if move.piece() == piece && move.to() == to && move.from() != from && move.valid(p, gen.pins) {
+				ambiguous = true
+				sameCol = sameCol || col(move.from()) == col(from)
+				sameRow = sameRow || row(move.from()) == row(from)
+			}
+		}
+		if ambiguous {
+			if !sameCol {
+				buffer.WriteByte(byte(col(from)) + 'a')
+			} else if !sameRow {
+				buffer.WriteByte(byte(row(from)) + '1')
+			} else {
+				buffer.WriteByte(byte(col(from)) + 'a')
+				buffer.WriteByte(byte(row(from)) + '1')
+			}
+		}
+		if capture.some() {
+			buffer.WriteByte('x')
+		}
+		buffer.WriteByte(byte(col(to)) + 'a')
+		buffer.WriteByte(byte(row(to)) + '1')
+	}
+
+	// Append check or checkmate suffix.
+	position := p.makeMove(m)
+	if position.isInCheck(position.color) {
+		if NewGen(position, MaxPly).generateEvasions().anyValid() {
+			buffer.WriteByte('+')
+		} else {
+			buffer.WriteByte('#')
+		}
+	}
+	position.undoLastMove()
+
+	return buffer.String()
+}
+
 // Returns string representation of the move in long algebraic notation using
 // ASCII characters only.
 func (m Move) str() (str string) {
@@ -397,7 +473,7 @@ func (m Move) String() (str string) {
 
 	from, to, piece, capture := m.split()
 	if m.isCastle() {
-		if to > from {
+		if m.castleSide() == 0 {
 			return `0-0`
 		}
 		return `0-0-0`
@@ -237,3 +237,33 @@ func TestMove340(t *testing.T) {
 	expect.Eq(t, bK & isCapture, Move(0))
 	expect.Ne(t, bP & isCapture, Move(0)) // Ne() for Pawn.
 }
+
+// Standard algebraic notation.
+func TestMove400(t *testing.T) {
+	p := NewGame().start()
+	expect.Eq(t, NewMove(p, G1, F3).san(p), `Nf3`)
+	expect.Eq(t, NewMove(p, E2, E4).san(p), `e4`)
+
+	p = NewGame(`Kg1,e4`, `Kg8,d5`).start()
+	expect.Eq(t, NewMove(p, E4, D5).san(p), `exd5`)
+}
+
+func TestMove410(t *testing.T) {
+	p := NewGame(`Kg2,Ra1,Rf1,Rh5,Rh7`, `Kc8`).start()
+	expect.Eq(t, NewMove(p, A1, D1).san(p), `Rad1`)
+	expect.Eq(t, NewMove(p, H5, H6).san(p), `R5h6`)
+}
+
+func TestMove420(t *testing.T) {
+	p := NewGame(`Ke1,Rh1,e7`, `Kh8,g7,h7`).start()
+	queen, _, _, knight := NewPromotion(p, E7, E8)
+	expect.Eq(t, queen.san(p), `e8=Q#`)
+	expect.Eq(t, knight.san(p), `e8=N`)
+	expect.Eq(t, NewCastle(p, E1, G1).san(p), `O-O`)
+}
+
+func TestMove430(t *testing.T) {
+	p := NewGame(`Kg1,Qd1`, `Kd8`).start()
+	expect.Eq(t, NewMove(p, D1, D5).san(p), `Qd5+`)
+	expect.Eq(t, NewMove(p, D1, E2).san(p), `Qe2`)
+}
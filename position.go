@@ -41,6 +41,8 @@ func NewPosition(game *Game, white, black string) *Position {
 
 	p.setupSide(white, White).setupSide(black, Black)
 
+	setupCastles(White, E1, H1, A1)
+	setupCastles(Black, E8, H8, A8)
 	p.castles = castleKingside[White] | castleQueenside[White] | castleKingside[Black] | castleQueenside[Black]
 	if p.pieces[E1] != King || p.pieces[H1] != Rook {
 		p.castles &= ^castleKingside[White]
@@ -143,6 +145,44 @@ func NewInitialPosition(game *Game) *Position {
 	return NewPositionFromFEN(game, `rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1`)
 }
 
+// Returns Shredder-FEN of Chess960 starting position given its 0..959 index
+// using Scharnagl numbering, ex. 518 is the standard start. Optional second
+// index sets up black pieces for Double Fischer Random Chess (DFRC).
+func chess960Fen(index ...int) string {
+	rows, castles := [2]string{}, [2]string{}
+
+	for color := White; color <= Black; color++ {
+		n := index[min(color, len(index) - 1)]
+		row := [8]byte{}
+		empty := func(nth int) int { // Returns nth empty file.
+			for file := 0; file < 8; file++ {
+				if row[file] == 0 {
+					if nth == 0 {
+						return file
+					}
+					nth--
+				}
+			}
+			return -1
+		}
+
+		row[n % 4 * 2 + 1] = 'B'; n /= 4 // Light square bishop.
+		row[n % 4 * 2] = 'B'; n /= 4     // Dark square bishop.
+		row[empty(n % 6)] = 'Q'; n /= 6
+		knights := [10][2]int{ {0,1}, {0,2}, {0,3}, {0,4}, {1,2}, {1,3}, {1,4}, {2,3}, {2,4}, {3,4} }[n]
+		row[empty(knights[1])] = 'N' // Second knight first so that the first index stays intact.
+		row[empty(knights[0])] = 'N'
+
+		// Remaining three squares are rook, king, and rook.
+		castles[color] = string([]byte{ byte('A' + empty(2)), byte('A' + empty(0)) })
+		row[empty(0)] = 'R'; row[empty(0)] = 'K'; row[empty(0)] = 'R'
+		rows[color] = string(row[:])
+	}
+
+	return strings.ToLower(rows[Black]) + `/pppppppp/8/8/8/8/PPPPPPPP/` + rows[White] +
+		` w ` + castles[White] + strings.ToLower(castles[Black]) + ` - 0 1`
+}
+
 // Decodes FEN string and creates new position.
 func NewPositionFromFEN(game *Game, fen string) *Position {
 	tree[node] = Position{}
@@ -155,13 +195,20 @@ func NewPositionFromFEN(game *Game, fen string) *Position {
 	// [3] - En-passant square.
 	// [4] - Number of half-moves.
 	// [5] - Number of full moves.
-	matches := strings.Split(fen, ` `)
-	if len(matches) < 4 {
+	//
+	// Truncated FEN strings are accepted as long as they have the pieces and
+	// the side to move: missing castle rights and en-passant square default
+	// to none, missing number of half-moves defaults to 0.
+	matches := strings.Fields(fen)
+	if len(matches) < 2 {
 		return nil
 	}
+	for len(matches) < 6 {
+		matches = append(matches, []string{ `-`, `-`, `0`, `1` }[len(matches) - 2])
+	}
 
 	// [0] - Pieces (entire board).
-	sq := A8
+	sq, col, row := A8, 0, 0
 	for _, char := range(matches[0]) {
 		piece := Piece(0)
 		switch(char) {
@@ -192,42 +239,106 @@ func NewPositionFromFEN(game *Game, fen string) *Position {
 			piece = BlackKing
 			p.king[Black] = sq
 		case '/':
-			sq -= 16
+			if col != 8 || row == 7 {
+				return nil
+			}
+			sq -= 16; col = 0; row++
 		case '1', '2', '3', '4', '5', '6', '7', '8':
-			sq += int(char - '0')
+			sq += int(char - '0'); col += int(char - '0')
+			if col > 8 {
+				return nil
+			}
+		default:
+			return nil
 		}
 		if piece.some() {
+			if col == 8 {
+				return nil
+			}
 			p.pieces[sq] = piece
 			p.outposts[piece] |= bit[sq]
 			p.outposts[piece.color()] |= bit[sq]
 			p.balance += materialBalance[piece]
-			sq++
+			sq++; col++
 		}
 	}
 
+	// Reject incomplete board or missing kings.
+	if row != 7 || col != 8 || p.outposts[King].count() != 1 || p.outposts[BlackKing].count() != 1 {
+		return nil
+	}
+
 	// [1] - Color of side to move.
-	p.color = let(matches[1] == `w`, White, Black)
+	switch matches[1] {
+	case `w`:
+		p.color = White
+	case `b`:
+		p.color = Black
+	default:
+		return nil
+	}
 
-	// [2] - Castle rights.
+	// [2] - Castle rights. In Chess960 mode KQkq stand for the outermost rooks
+	// (X-FEN), and the rook files could also be specified explicitly, ex. HAha
+	// (Shredder-FEN).
+	kingside, queenside := [2]int{ H1, H8 }, [2]int{ A1, A8 }
+	outermost := func(color, from, step int) int {
+		for square := from; square != p.king[color]; square += step {
+			if p.pieces[square] == rook(color) {
+				return square
+			}
+		}
+		return from
+	}
 	for _, char := range(matches[2]) {
 		switch(char) {
 		case 'K':
 			p.castles |= castleKingside[White]
+			if engine.chess960 {
+				kingside[White] = outermost(White, H1, -1)
+			}
 		case 'Q':
 			p.castles |= castleQueenside[White]
+			if engine.chess960 {
+				queenside[White] = outermost(White, A1, 1)
+			}
 		case 'k':
 			p.castles |= castleKingside[Black]
+			if engine.chess960 {
+				kingside[Black] = outermost(Black, H8, -1)
+			}
 		case 'q':
 			p.castles |= castleQueenside[Black]
+			if engine.chess960 {
+				queenside[Black] = outermost(Black, A8, 1)
+			}
+		case 'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'a', 'b', 'c', 'd', 'e', 'f', 'g', 'h':
+			if engine.chess960 {
+				color := let(char >= 'a', Black, White)
+				square := square(color * A8H8, int(char) - let(char >= 'a', 'a', 'A'))
+				if square > p.king[color] {
+					p.castles |= castleKingside[color]
+					kingside[color] = square
+				} else {
+					p.castles |= castleQueenside[color]
+					queenside[color] = square
+				}
+			}
 		case '-':
 			// No castling rights.
 		}
 	}
+	for color := White; color <= Black; color++ {
+		if engine.chess960 && p.castles & (castleKingside[color] | castleQueenside[color]) != 0 {
+			setupCastles(color, p.king[color], kingside[color], queenside[color])
+		} else {
+			setupCastles(color, E1 + color * A8, H1 + color * A8, A1 + color * A8)
+		}
+	}
 
 	// [3] - En-passant square.
-	if matches[3] != `-` {
-		p.enpassant = square(int(matches[3][1] - '1'), int(matches[3][0] - 'a'))
-
+	if ep := matches[3]; len(ep) == 2 && ep[0] >= 'a' && ep[0] <= 'h' && ep[1] >= '1' && ep[1] <= '8' {
+		p.enpassant = square(int(ep[1] - '1'), int(ep[0] - 'a'))
 	}
 
 	// [4] - Number of half-moves.
@@ -235,6 +346,11 @@ func NewPositionFromFEN(game *Game, fen string) *Position {
 		p.count50 = n
 	}
 
+	// [5] - Number of full moves.
+	if n, err := strconv.Atoi(matches[5]); err == nil && n > 0 {
+		game.firstMove = n
+	}
+
 	p.reversible = true
 	p.board = p.outposts[White] | p.outposts[Black]
 	p.id, p.pawnId = p.polyglot()
@@ -244,6 +360,42 @@ func NewPositionFromFEN(game *Game, fen string) *Position {
 	return p
 }
 
+// Sets up castle lookup tables for the given side. In standard chess the king
+// starts on E1/E8 and the castle rooks on H1/A1 and H8/A8. In Chess960 they
+// could start on any back rank squares, while after castle the king and rook
+// end up on the same squares as in standard chess.
+func setupCastles(color, king, kingside, queenside int) {
+	span := func(from, to int) (mask Bitmask) {
+		for square := min(from, to); square <= max(from, to); square++ {
+			mask |= bit[square]
+		}
+		return mask
+	}
+
+	home := color * A8
+	homeKing[color] = king
+	castleRook[color] = [2]int{ kingside, queenside }
+	gapKing[color] = (span(king, G1 + home) | span(kingside, F1 + home)) & ^(bit[king] | bit[kingside])
+	gapQueen[color] = (span(king, C1 + home) | span(queenside, D1 + home)) & ^(bit[king] | bit[queenside])
+	castleKing[color] = span(king, G1 + home)
+	castleQueen[color] = span(king, C1 + home)
+
+	for square := A1 + home; square <= H1 + home; square++ {
+		castleRights[square] = 15
+	}
+	castleRights[king] &= ^(castleKingside[color] | castleQueenside[color])
+	castleRights[kingside] &= ^castleKingside[color]
+	castleRights[queenside] &= ^castleQueenside[color]
+}
+
+// Returns true if incrementally updated pawn hash matches the one calculated
+// from scratch.
+func (p *Position) verifyPawnId() bool {
+	_, pawnHash := p.polyglot()
+
+	return p.pawnId == pawnHash
+}
+
 // Computes initial values of position's polyglot hash and pawn hash. When
 // making a move these values get updated incrementally.
 func (p *Position) polyglot() (hash, pawnHash uint64) {
@@ -268,6 +420,40 @@ func (p *Position) polyglot() (hash, pawnHash uint64) {
 	return hash, pawnHash
 }
 
+// Returns standard Polyglot hash key for the position. Unlike p.id the key is
+// computed from scratch and the en-passant square is only hashed when the side
+// to move has a pawn that could actually capture en-passant (this is what
+// Polyglot tools and books expect).
+func (p *Position) PolyglotKey() (key uint64) {
+	for board := p.board; board.any(); board = board.pop() {
+		square := board.first()
+		key ^= p.pieces[square].polyglot(square)
+	}
+
+	key ^= hashCastle[p.castles]
+	if p.enpassant != 0 && (maskPawn[p.color][p.enpassant] & p.outposts[pawn(p.color)]).any() {
+		key ^= hashEnpassant[p.enpassant & 7] // p.enpassant column.
+	}
+	if p.color == White {
+		key ^= polyglotRandomWhite
+	}
+
+	return key
+}
+
+// Returns a copy of Zobrist random table we use to hash positions: 768 piece
+// square keys, 4 castle keys, 8 en-passant keys, and the key for White to move.
+// The table is the standard Polyglot Random64 array so the keys are the same
+// for every build and can be reproduced by the external tools.
+func DumpZobrist() []uint64 {
+	table := make([]uint64, 0, len(polyglotRandom) + len(polyglotRandomCastle) + len(polyglotRandomEnpassant) + 1)
+	table = append(table, polyglotRandom[:]...)
+	table = append(table, polyglotRandomCastle[:]...)
+	table = append(table, polyglotRandomEnpassant[:]...)
+
+	return append(table, polyglotRandomWhite)
+}
+
 // Computes positional valuation score based on PST. When making a move the
 // valuation tally gets updated incrementally.
 func (p *Position) valuation() (score Score) {
@@ -280,11 +466,42 @@ func (p *Position) valuation() (score Score) {
 	return score
 }
 
+// Returns an independent copy of the position. Position has no references,
+// so copying the struct duplicates the board, hash keys, castle rights, and
+// counters. The clone lives outside the search tree: any moves made on it go
+// to the next tree node as usual, and the original position stays intact.
+func (p *Position) Clone() *Position {
+	clone := *p
+	return &clone
+}
+
+// Returns game phase based on available material: 256 for full set of pieces
+// going down to 0 when only kings and pawns are left.
+func (p *Position) Phase() int {
+	return materialBase[p.balance].phase
+}
+
 // Returns true if material balance is insufficient to win the game.
 func (p *Position) insufficient() bool {
 	return materialBase[p.balance].flags & materialDraw != 0
 }
 
+// Returns true if neither side can possibly checkmate: bare kings, a single
+// minor piece, or any number of bishops all on the same colored squares.
+func (p *Position) insufficientMaterial() bool {
+	outposts := &p.outposts
+	if (outposts[Pawn] | outposts[BlackPawn] | outposts[Rook] | outposts[BlackRook] | outposts[Queen] | outposts[BlackQueen]).any() {
+		return false
+	}
+
+	knights, bishops := outposts[Knight] | outposts[BlackKnight], outposts[Bishop] | outposts[BlackBishop]
+	if (knights | bishops).count() <= 1 {
+		return true
+	}
+
+	return knights.empty() && ((bishops & maskDark).empty() || (bishops & ^maskDark).empty())
+}
+
 // Reports game status for current position or after the given move. The status
 // helps to determine whether to continue with search or if the game is over.
 func (p *Position) status(move Move, blendedScore int) int {
@@ -362,20 +579,29 @@ func (p *Position) fen() (fen string) {
 		fen += ` b`
 	}
 
-	// Castle rights for both sides, if any.
+	// Castle rights for both sides, if any. In Chess960 mode use castle rook
+	// files (Shredder-FEN).
 	if p.castles & 0x0F != 0 {
 		fen += ` `
+		letters := [2][2]byte{ { 'K', 'Q' }, { 'k', 'q' } }
+		if engine.chess960 {
+			for color := White; color <= Black; color++ {
+				for side := 0; side < 2; side++ {
+					letters[color][side] = byte(col(castleRook[color][side]) + let(color == White, 'A', 'a'))
+				}
+			}
+		}
 		if p.castles & castleKingside[White] != 0 {
-			fen += `K`
+			fen += string(letters[White][0])
 		}
 		if p.castles & castleQueenside[White] != 0 {
-			fen += `Q`
+			fen += string(letters[White][1])
 		}
 		if p.castles & castleKingside[Black] != 0 {
-			fen += `k`
+			fen += string(letters[Black][0])
 		}
 		if p.castles & castleQueenside[Black] != 0 {
-			fen += `q`
+			fen += string(letters[Black][1])
 		}
 	} else {
 		fen += ` -`
@@ -392,8 +618,15 @@ func (p *Position) fen() (fen string) {
 	// Number of half-moves (50 moves counter).
 	fen += fmt.Sprintf(` %d`, p.count50)
 
-	// TODO: Number of full moves.
-	fen += ` 1`
+	// Number of full moves counted from the initial position.
+	moves := game.firstMove
+	for ply := 0; ply <= node; ply++ {
+		if p == &tree[ply] {
+			moves += (ply + let(tree[0].color == Black, 1, 0)) / 2
+			break
+		}
+	}
+	fen += fmt.Sprintf(` %d`, moves)
 
 	return
 }
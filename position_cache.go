@@ -29,6 +29,17 @@ type CacheEntry struct {
 
 type Cache []CacheEntry
 
+type PerftEntry struct {
+	id	uint64	// Position hash key.
+	depth	int	// Perft depth.
+	nodes	int64	// Number of leaf nodes.
+}
+
+type PerftCache []PerftEntry
+
+// Optional cache of perft node counts, disabled when empty.
+var perftCache PerftCache
+
 func cacheUsage() (hits int) {
 	for i := 0; i < len(game.cache); i++ {
 		if game.cache[i].id != uint16(0) {
@@ -67,15 +78,9 @@ func (ce *CacheEntry) bounds() uint8 {
 func NewCache(megaBytes float64) Cache {
 	if megaBytes > 0.0 {
 		cacheSize := int(1024 * 1024 * megaBytes) / cacheEntrySize
-		// Cache size has changed.
-		if existing := len(game.cache); cacheSize != existing {
-			if existing == 0 {
-				// Create brand new zero-initialized cache.
-				return make(Cache, cacheSize)
-			} else {
-				// Reallocate existing cache (shrink or expand).
-				game.cache = append([]CacheEntry{}, game.cache[:cacheSize]...)
-			}
+		// Cache size has changed: create brand new zero-initialized cache.
+		if cacheSize != len(game.cache) {
+			return make(Cache, cacheSize)
 		}
 		// Make sure the cache is all clear.
 		for i := 0; i < len(game.cache); i++ {
@@ -87,6 +92,45 @@ func NewCache(megaBytes float64) Cache {
 	return nil
 }
 
+// Resizes the cache preserving existing entries where possible. The entries
+// only keep upper 16 bits of the position hash while the lower bits are implied
+// by the entry index. When the cache grows the hash bits that are needed for
+// the new index might be unknown, and the entry gets copied over to all the
+// candidate slots: probing checks the upper bits anyway. The cache that grows
+// more than 16 times gets reset instead.
+func (game *Game) rehashCache(megaBytes float64) *Game {
+	size := int(1024 * 1024 * megaBytes) / cacheEntrySize
+	if size == len(game.cache) || size <= 0 {
+		return game
+	}
+
+	cache := make(Cache, size)
+	oldMask, newMask := uint64(len(game.cache) - 1), uint64(size - 1)
+	unknown := newMask & ^oldMask & (uint64(1) << 48 - 1)
+	if len(game.cache) == 0 || Bitmask(unknown).count() > 4 {
+		game.cache = cache
+		return game
+	}
+
+	for i, entry := range game.cache {
+		if entry.id == 0 {
+			continue
+		}
+		key := uint64(entry.id) << 48 | uint64(i)
+		for bits := uint64(0); ; {
+			if slot := &cache[(key | bits) & newMask]; slot.id == 0 || entry.depth() > slot.depth() {
+				*slot = entry
+			}
+			if bits = (bits - unknown) & unknown; bits == 0 {
+				break
+			}
+		}
+	}
+	game.cache = cache
+
+	return game
+}
+
 func (p *Position) cache(move Move, score, depth, ply int, flags uint8) *Position {
 	if cacheSize := len(game.cache); cacheSize > 0 {
 		index := p.id & uint64(cacheSize - 1)
@@ -107,6 +151,7 @@ func (p *Position) cache(move Move, score, depth, ply int, flags uint8) *Positio
 			entry.xdepth = int8(depth)
 			entry.flags = flags | game.token
 			entry.id = id
+			game.stats.CacheStores++
 		}
 	}
 
@@ -117,9 +162,25 @@ func (p *Position) probeCache() *CacheEntry {
 	if cacheSize := len(game.cache); cacheSize > 0 {
 		index := p.id & uint64(cacheSize - 1)
 		if entry := &game.cache[index]; entry.id == uint16(p.id >> 48) {
+			game.stats.CacheHits++
 			return entry
 		}
 	}
 
 	return nil
 }
+
+// Creates new perft cache of given size, or disables it if the size is zero.
+// Since perft is exact the cached node counts are always safe to reuse.
+func NewPerftCache(megaBytes float64) PerftCache {
+	perftCache = nil
+	if entries := int(1024 * 1024 * megaBytes) / int(unsafe.Sizeof(PerftEntry{})); entries > 0 {
+		size := 1
+		for size * 2 <= entries { // Round down to the power of 2.
+			size *= 2
+		}
+		perftCache = make(PerftCache, size)
+	}
+
+	return perftCache
+}
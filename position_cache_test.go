@@ -23,3 +23,39 @@ func TestCache000(t *testing.T) {
 	expect.Eq(t, cached.flags, uint8(cacheExact | game.token))
 	expect.Eq(t, cached.id, uint16(p.id >> 48))
 }
+
+// Resizing the cache discards existing entries unless asked to preserve them.
+func TestCache010(t *testing.T) {
+	cacheSize := engine.cacheSize; defer func() { engine.cacheSize = cacheSize; NewGame() }()
+	engine.cacheSize = 1
+	p := NewGame().start()
+
+	positions, moves := []*Position{}, []Move{}
+	for _, move := range []Move{ NewMove(p, E2, E4), NewMove(p, D2, D4), NewMove(p, G1, F3), NewMove(p, C2, C4) } {
+		position := *p.makeMove(move)
+		positions, moves = append(positions, &position), append(moves, move)
+		position.cache(move, 42, 12, 0, cacheExact)
+		p = p.undoLastMove()
+	}
+
+	game.rehashCache(4)
+	expect.Eq(t, len(game.cache), 4 * 1024 * 1024 / cacheEntrySize)
+	for i, position := range positions {
+		cached := position.probeCache()
+		expect.True(t, cached != nil)
+		expect.Eq(t, cached.move, moves[i])
+		expect.Eq(t, cached.depth(), 12)
+		expect.Eq(t, cached.score(0), 42)
+	}
+
+	game.rehashCache(2)
+	expect.Eq(t, len(game.cache), 2 * 1024 * 1024 / cacheEntrySize)
+	for _, position := range positions {
+		expect.True(t, position.probeCache() != nil)
+	}
+
+	game.cache = NewCache(8)
+	for _, position := range positions {
+		expect.True(t, position.probeCache() == nil)
+	}
+}
@@ -18,7 +18,10 @@ var exchangeScores = []int{
 	valueQueen.midgame * 8, valueQueen.midgame * 8, // King/BlackKing specials.
 }
 
-// Static exchange evaluation.
+// Static exchange evaluation (SEE). Simulates the sequence of captures on the
+// target square with the least valuable attacker going first. Sliding pieces
+// lined up behind the capturing piece join the exchange as the board gets
+// cleared. Negative score means the capture loses material.
 func (p *Position) exchange(move Move) int {
 	from, to, piece, capture := move.split()
 
@@ -32,6 +35,44 @@ func (p *Position) exchange(move Move) int {
 	return -p.exchangeScore(piece.color()^1, to, -score, exchangeScores[piece], board)
 }
 
+// Returns all valid captures in the position along with their static exchange
+// values. Captures that lose material come with negative values.
+func (p *Position) CaptureScores() (scores []struct{ Move Move; See int }) {
+	gen := NewMoveGen(p).generateCaptures().validOnly()
+	for move := gen.nextMove(); move.some(); move = gen.nextMove() {
+		if move.isCapture() {
+			scores = append(scores, struct{ Move Move; See int }{ move, p.exchange(move) })
+		}
+	}
+
+	return scores
+}
+
+// Returns true if the position is tactically sharp so that its static score
+// is not reliable: the side to move is in check, has a capture that wins
+// material, or can give a check without losing the checking piece.
+func (p *Position) IsTactical() bool {
+	if p.isInCheck(p.color) {
+		return true
+	}
+
+	gen := NewMoveGen(p).generateCaptures().validOnly()
+	for move := gen.nextMove(); move.some(); move = gen.nextMove() {
+		if move.isCapture() && p.exchange(move) > 0 {
+			return true
+		}
+	}
+
+	gen = NewMoveGen(p).generateChecks().validOnly()
+	for move := gen.nextMove(); move.some(); move = gen.nextMove() {
+		if p.exchange(move) >= 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
 // Recursive helper method for the static exchange evaluation.
 func (p *Position) exchangeScore(color int, to, score, extra int, board Bitmask) int {
 	attackers := p.attackers(color, to, board) & board
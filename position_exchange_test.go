@@ -27,3 +27,53 @@ func TestExchange020(t *testing.T) { // c4,d4,e4 vs. c6,d5,e6 (white wins a pawn
 	exchange := p.exchange(NewMove(p, E4, D5))
 	expect.Eq(t, exchange, valuePawn.midgame)
 }
+
+func TestExchange030(t *testing.T) { // Rook takes pawn protected by pawn.
+	p := NewGame(`Kg1,Rd1`, `Kg8,d5,e6`).start()
+	exchange := p.exchange(NewMove(p, D1, D5))
+	expect.Eq(t, exchange, valuePawn.midgame - valueRook.midgame)
+}
+
+func TestExchange040(t *testing.T) { // Doubled rooks x-ray through each other.
+	p := NewGame(`Kg1,Rd1,Rd2`, `Kg8,Rd8,d5`).start()
+	exchange := p.exchange(NewMove(p, D2, D5))
+	expect.Eq(t, exchange, valuePawn.midgame)
+}
+
+func TestExchange050(t *testing.T) { // Queen behind bishop joins the exchange.
+	p := NewGame(`Kg1,Qb2,Bc3`, `Kg8,Nf6,Ne8`).start()
+	exchange := p.exchange(NewMove(p, C3, F6))
+	expect.Eq(t, exchange, valueKnight.midgame * 2 - valueBishop.midgame)
+}
+
+// Captures with static exchange values.
+func TestExchange400(t *testing.T) {
+	p := NewGame(`Kg1,Qd1,Nc3`, `Kg8,Nb5,d5,e6`).start()
+	scores := map[Move]int{}
+	for _, capture := range p.CaptureScores() {
+		scores[capture.Move] = capture.See
+	}
+
+	expect.Eq(t, len(scores), 3)
+	expect.Eq(t, scores[NewMove(p, C3, B5)], valueKnight.midgame)
+	expect.Eq(t, scores[NewMove(p, C3, D5)], 2 * valuePawn.midgame - valueKnight.midgame)
+	expect.Eq(t, scores[NewMove(p, D1, D5)], 2 * valuePawn.midgame - valueQueen.midgame)
+}
+
+// Tactical alert for hanging pieces, checks, and quiet positions.
+func TestExchange410(t *testing.T) {
+	p := NewGame(`Kg1,Rd1,a2,b2`, `Kg8,Qd5,a7,b7`).start() // Hanging queen.
+	expect.True(t, p.IsTactical())
+
+	p = NewGame(`Kg1,Rd1,a2,b2`, `Kg8,a7,b7`).start() // Back rank check.
+	expect.True(t, p.IsTactical())
+
+	p = NewGame(`Kg1,Rd1,a2,b2`, `M,Kg8,a7,b7`).start() // Nothing to do for Black.
+	expect.False(t, p.IsTactical())
+
+	p = NewGame(`Kg1,Rd8,a2,b2`, `M,Kg8,a7,b7`).start() // In check.
+	expect.True(t, p.IsTactical())
+
+	p = NewGame(`Kg1,a2,b3`, `Kg8,a7,b6`).start() // Quiet endgame.
+	expect.False(t, p.IsTactical())
+}
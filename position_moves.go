@@ -62,6 +62,28 @@ func (p *Position) capturePiece(capture Piece, from, to int) *Position {
 	return p
 }
 
+// Moves the king and castle rook. In Chess960 the king and rook squares might
+// overlap (or the king might not move at all) so we lift both pieces off the
+// board first and then set them on their target squares.
+func (p *Position) castle(color, from, to, side int) *Position {
+	king, rook := king(color), rook(color)
+	rookFrom, rookTo := castleRook[color][side], let(side == 0, F1, D1) + color * A8
+
+	p.pieces[from], p.pieces[rookFrom] = 0, 0
+	p.pieces[to], p.pieces[rookTo] = king, rook
+	p.outposts[king] ^= bit[from] ^ bit[to]
+	p.outposts[rook] ^= bit[rookFrom] ^ bit[rookTo]
+	p.outposts[color] ^= bit[from] ^ bit[to] ^ bit[rookFrom] ^ bit[rookTo]
+
+	// Update position's hash values.
+	p.id ^= king.polyglot(from) ^ king.polyglot(to) ^ rook.polyglot(rookFrom) ^ rook.polyglot(rookTo)
+
+	// Update positional score.
+	p.tally.sub(pst[king][from]).add(pst[king][to]).sub(pst[rook][rookFrom]).add(pst[rook][rookTo])
+
+	return p
+}
+
 func (p *Position) captureEnpassant(capture Piece, from, to int) *Position {
 	enpassant := to - up[capture.color()^1]
 
@@ -90,7 +112,11 @@ func (p *Position) makeMove(move Move) *Position {
 	tree[node] = *p // => tree[node] = tree[node - 1]
 	pp := &tree[node]
 
+	// Previous move's en-passant square expires no matter what move we make.
 	pp.enpassant, pp.reversible = 0, true
+	if p.enpassant != 0 {
+		pp.id ^= hashEnpassant[p.enpassant & 7] // p.enpassant column.
+	}
 
 	if capture != 0 && (to == 0 || to != p.enpassant) {
 		pp.count50, pp.reversible = 0, false
@@ -101,7 +127,6 @@ func (p *Position) makeMove(move Move) *Position {
 		pp.count50, pp.reversible = 0, false
 		if to != 0 && to == p.enpassant {
 			pp.captureEnpassant(pawn(color^1), from, to)
-			pp.id ^= hashEnpassant[p.enpassant & 7] // p.enpassant column.
 		}
 		if promo := move.promo(); promo != 0 {
 			pp.promotePawn(piece, from, to, promo)
@@ -113,17 +138,14 @@ func (p *Position) makeMove(move Move) *Position {
 			}
 		}
 	} else if piece.isKing() {
-		pp.movePiece(piece, from, to)
-		pp.count50++
-		pp.king[color] = to
 		if move.isCastle() {
+			pp.castle(color, from, to, move.castleSide())
 			pp.reversible = false
-			if to == from + 2 {
-				pp.movePiece(rook(color), to + 1, to - 1)
-			} else if to == from - 2 {
-				pp.movePiece(rook(color), to - 2, to + 1)
-			}
+		} else {
+			pp.movePiece(piece, from, to)
 		}
+		pp.count50++
+		pp.king[color] = to
 	} else {
 		pp.movePiece(piece, from, to)
 		pp.count50++
@@ -143,7 +165,7 @@ func (p *Position) makeMove(move Move) *Position {
 
 // Makes "null" move by copying over previous node position (i.e. preserving all pieces
 // intact) and flipping the color.
-func (p *Position) makeNullMove() *Position {
+func (p *Position) makeNull() *Position {
 	node++
 	tree[node] = *p // => tree[node] = tree[node - 1]
 	pp := &tree[node]
@@ -156,10 +178,17 @@ func (p *Position) makeNullMove() *Position {
 	pp.id ^= polyglotRandomWhite
 	pp.color ^= 1 // <-- Flip side to move.
 	pp.count50++
+	pp.score = Unknown // Evaluation score belongs to the other side.
 
 	return &tree[node] // pp
 }
 
+// Takes back the null move. Since previous node position remains intact its
+// hash value and en-passant square get restored as well.
+func (p *Position) unmakeNull() *Position {
+	return p.undoLastMove()
+}
+
 // Restores previous position effectively taking back the last move made.
 func (p *Position) undoLastMove() *Position {
 	if node > 0 {
@@ -180,17 +209,20 @@ func (p *Position) fifty() bool {
 	return p.count50 >= 100
 }
 
+// Returns true if the position should be scored as a draw by repetition. Within
+// the search tree the second occurrence is enough since the side that could
+// avoid the repetition would have done so already, while the positions from
+// the game history before the root have to repeat three times.
 func (p *Position) repetition() bool {
 	if !p.reversible || node < 1 {
 		return false
 	}
 
-	for previous := node - 1; previous >= 0; previous-- {
-		if !tree[previous].reversible {
-			return false
-		}
+	for previous, repetitions := node - 2, 1; previous >= max(0, node - p.count50); previous -= 2 {
 		if tree[previous].id == p.id {
-			return true
+			if repetitions++; previous >= rootNode || repetitions == 3 {
+				return true
+			}
 		}
 	}
 
@@ -198,23 +230,22 @@ func (p *Position) repetition() bool {
 }
 
 func (p *Position) thirdRepetition() bool {
-	if !p.reversible || node < 4 {
-		return false
-	}
+	return p.repetitionCount() >= 3
+}
 
-	for previous, repetitions := node - 2, 1; previous >= 0; previous -= 2 {
-		if !tree[previous].reversible || !tree[previous + 1].reversible {
-			return false
-		}
+// Returns the number of times the position has occurred in the game including
+// the position itself. Tree nodes serve as the stack of position hashes that
+// gets maintained by making and taking back the moves, and we only go back as
+// far as the last capture or pawn move, i.e. the fifty moves reset point.
+func (p *Position) repetitionCount() int {
+	repetitions := 1
+	for previous := node - 2; previous >= max(0, node - p.count50); previous -= 2 {
 		if tree[previous].id == p.id {
 			repetitions++
-			if repetitions == 3 {
-				return true
-			}
 		}
 	}
 
-	return false
+	return repetitions
 }
 
 // Returns a pair of booleans that indicate whether given side is allowed to
@@ -222,8 +253,9 @@ func (p *Position) thirdRepetition() bool {
 func (p *Position) canCastle(color int) (kingside, queenside bool) {
 
 	// Start off with simple checks.
-	kingside = (p.castles & castleKingside[color] != 0) && (gapKing[color] & p.board).empty()
-	queenside = (p.castles & castleQueenside[color] != 0) && (gapQueen[color] & p.board).empty()
+	rooks := p.outposts[rook(color)]
+	kingside = (p.castles & castleKingside[color] != 0) && rooks.on(castleRook[color][0]) && (gapKing[color] & p.board).empty()
+	queenside = (p.castles & castleQueenside[color] != 0) && rooks.on(castleRook[color][1]) && (gapQueen[color] & p.board).empty()
 
 	// If it still looks like the castles are possible perform more expensive
 	// final check.
@@ -233,9 +265,24 @@ func (p *Position) canCastle(color int) (kingside, queenside bool) {
 		queenside = queenside && (castleQueen[color] & attacks).empty()
 	}
 
+	// In Chess960 castle rook might be shielding the king's target square
+	// from enemy rook or queen on the back rank.
+	if engine.chess960 {
+		kingside = kingside && p.isCastleSafe(color, G1 + color * A8)
+		queenside = queenside && p.isCastleSafe(color, C1 + color * A8)
+	}
+
 	return kingside, queenside
 }
 
+// Returns true if the king is not in check after castle.
+func (p *Position) isCastleSafe(color, to int) bool {
+	position := p.makeMove(NewCastle(p, p.king[color], to))
+	defer position.undoLastMove()
+
+	return !position.isInCheck(color)
+}
+
 // Returns a bitmask of all pinned pieces preventing a check for the king on
 // given square. The color of the pieces match the color of the king.
 func (p *Position) pins(square int) (bitmask Bitmask) {
@@ -8,7 +8,7 @@
 
 package donna
 
-import(`github.com/michaeldv/donna/expect`; `testing`)
+import(`github.com/michaeldv/donna/expect`; `math/rand`; `testing`)
 
 func TestPositionMoves010(t *testing.T) {
 	p := NewGame(`Ke1,e2`, `Kg8,d7,f7`).start()
@@ -599,14 +599,65 @@ func TestPositionMoves350(t *testing.T) {
 func TestPositionMoves400(t *testing.T) {
 	p := NewGame(`Ke1,Qd1,d2,e2`, `Kg8,Qf8,f7,g7`).start()
 
-	p = p.makeNullMove()
+	p = p.makeNull()
 	expect.True(t, p.isNull())
 
-	p = p.undoLastMove()
+	p = p.unmakeNull()
 	p = p.makeMove(NewMove(p, E2, E4))
 	expect.False(t, p.isNull())
 }
 
+// Null move with active en-passant square.
+func TestPositionMoves405(t *testing.T) {
+	p := NewGame(`Ke1,e2`, `Kg8,d4`).start()
+	p = p.makeMove(NewEnpassant(p, E2, E4))
+	before := *p
+	expect.Eq(t, p.enpassant, E3)
+
+	position := p.makeNull()
+	hash, _ := position.polyglot()
+	expect.Eq(t, position.enpassant, 0)
+	expect.Eq(t, position.color, White)
+	expect.Eq(t, position.id, hash)
+
+	position = position.unmakeNull()
+	expect.True(t, *position == before)
+	expect.Eq(t, position.id, before.id)
+	expect.Eq(t, position.enpassant, E3)
+}
+
+// Pawn hash after promotion.
+func TestPositionMoves420(t *testing.T) {
+	p := NewGame(`Ke1,b7,g7,h2`, `Ke8,Rh8,a7`).start()
+	p = p.makeMove(NewMove(p, B7, B8).promote(Queen))
+	expect.True(t, p.verifyPawnId())
+
+	p = p.makeMove(NewMove(p, E8, D7))
+	p = p.makeMove(NewMove(p, G7, H8).promote(Knight)) // Promo with capture.
+	expect.True(t, p.verifyPawnId())
+
+	// Pawn structure is the same as if promoted pawns were never there.
+	_, metrics := p.EvaluateWithTrace()
+	promoted := metrics[`Pawns`].(Total)
+	_, metrics = NewGame(`Ke1,Qb8,Nh8,h2`, `M,Kd7,a7`).start().EvaluateWithTrace()
+	expect.Eq(t, promoted, metrics[`Pawns`].(Total))
+}
+
+// Pawn hash after en-passant capture.
+func TestPositionMoves430(t *testing.T) {
+	p := NewGame(`Ke1,e5,h2`, `M,Ke8,d7,a7`).start()
+	p = p.makeMove(NewEnpassant(p, D7, D5))
+	p = p.makeMove(NewMove(p, E5, D6))
+	expect.Eq(t, p.pieces[D5], Piece(0))
+	expect.True(t, p.verifyPawnId())
+
+	// Pawn structure reflects the captured pawn gone from D5.
+	_, metrics := p.EvaluateWithTrace()
+	captured := metrics[`Pawns`].(Total)
+	_, metrics = NewGame(`Ke1,d6,h2`, `M,Ke8,a7`).start().EvaluateWithTrace()
+	expect.Eq(t, captured, metrics[`Pawns`].(Total))
+}
+
 // isInCheck
 func TestPositionMoves410(t *testing.T) {
 	p := NewGame().start()
@@ -617,3 +668,157 @@ func TestPositionMoves410(t *testing.T) {
 	expect.True(t, position.isInCheck(position.color))
 	expect.True(t, position.isInCheck(p.color^1))
 }
+
+// En-passant square gets removed from the hash when the opponent makes other move.
+func TestPositionMoves440(t *testing.T) {
+	p := NewGame(`Ke1,e5,h2`, `M,Ke8,d7,a7`).start()
+	p = p.makeMove(NewEnpassant(p, D7, D5))
+	p = p.makeMove(NewMove(p, H2, H3))
+	hash, _ := p.polyglot()
+	expect.Eq(t, p.enpassant, 0)
+	expect.Eq(t, p.id, hash)
+}
+
+// Chess960: king on B1 with adjacent rooks castles kingside onto G1 while the
+// queenside castle is blocked by the other rook.
+func TestPositionMoves450(t *testing.T) {
+	engine.chess960 = true; defer func() { engine.chess960 = false; NewGame().start() }()
+	p := NewGame(`4k3/8/8/8/8/8/8/RKR5 w CA - 0 1`).start()
+	expect.Eq(t, p.castles, castleKingside[White] | castleQueenside[White])
+	expect.Eq(t, p.fen(), `4k3/8/8/8/8/8/8/RKR5 w CA - 0 1`)
+
+	kingside, queenside := p.canCastle(White)
+	expect.True(t, kingside)
+	expect.False(t, queenside)
+
+	move := NewMoveFromNotation(p, `b1c1`)
+	expect.True(t, move.isCastle())
+	expect.Eq(t, move.notation(), `b1c1`)
+	expect.Eq(t, move.san(p), `O-O`)
+
+	before := *p
+	position := p.makeMove(move)
+	hash, pawnHash := position.polyglot()
+	expect.Eq(t, position.pieces[G1], Piece(King))
+	expect.Eq(t, position.pieces[F1], Piece(Rook))
+	expect.Eq(t, position.pieces[A1], Piece(Rook))
+	expect.Eq(t, position.pieces[B1] | position.pieces[C1], Piece(0))
+	expect.Eq(t, position.king[White], G1)
+	expect.Eq(t, position.castles, uint8(0))
+	expect.Eq(t, position.id, hash)
+	expect.Eq(t, position.pawnId, pawnHash)
+	expect.Eq(t, position.undoLastMove().id, before.id)
+	expect.True(t, *p == before)
+}
+
+// Chess960: king on B1 castles queenside onto C1 next to its rook, and the
+// castle rook might not leave the king exposed to back rank attack.
+func TestPositionMoves460(t *testing.T) {
+	engine.chess960 = true; defer func() { engine.chess960 = false; NewGame().start() }()
+	p := NewGame(`4k3/8/8/8/8/8/8/RK5R w HA - 0 1`).start()
+	move := NewMoveFromNotation(p, `b1a1`)
+	expect.True(t, NewMoveGen(p).generateAllMoves().validOnly().amongValid(move))
+
+	position := p.makeMove(move)
+	hash, _ := position.polyglot()
+	expect.Eq(t, position.pieces[C1], Piece(King))
+	expect.Eq(t, position.pieces[D1], Piece(Rook))
+	expect.Eq(t, position.pieces[A1] | position.pieces[B1], Piece(0))
+	expect.Eq(t, position.id, hash)
+	position.undoLastMove()
+
+	p = NewGame(`4k3/8/8/8/8/8/8/qRK5 w B - 0 1`).start()
+	_, queenside := p.canCastle(White)
+	expect.False(t, queenside)
+}
+
+// Chess960: king and rook swap squares when castling.
+func TestPositionMoves470(t *testing.T) {
+	engine.chess960 = true; defer func() { engine.chess960 = false; NewGame().start() }()
+	p := NewGame(`k7/8/8/8/8/8/8/4RKR1 w GE - 0 1`).start()
+	move := NewMoveFromNotation(p, `f1g1`)
+	expect.Eq(t, move.san(p), `O-O`)
+
+	position := p.makeMove(move)
+	hash, _ := position.polyglot()
+	expect.Eq(t, position.pieces[G1], Piece(King))
+	expect.Eq(t, position.pieces[F1], Piece(Rook))
+	expect.Eq(t, position.pieces[E1], Piece(Rook))
+	expect.Eq(t, position.outposts[White], bit[E1] | bit[F1] | bit[G1])
+	expect.Eq(t, position.id, hash)
+	expect.Eq(t, position.castles & castleQueenside[White], uint8(0))
+}
+
+// Incrementally updated pawn hash must match the one computed from scratch
+// at every ply of random legal games, both when making and taking back moves.
+func TestPositionMoves480(t *testing.T) {
+	defer func() { NewGame().start() }()
+	promos, enpassants := 0, 0
+
+	for seed := int64(1); seed <= 20; seed++ {
+		rnd := rand.New(rand.NewSource(seed))
+		p := NewGame(`r3k2r/1P4P1/8/2pPp3/3pPp2/8/1p4p1/R3K2R w KQkq c6 0 1`).start()
+		if seed % 2 == 0 {
+			p = NewGame().start()
+		}
+		for ply := 0; ply < 200; ply++ {
+			moves := NewGen(p, MaxPly).generateAllMoves().validOnly().allMoves()
+			if len(moves) == 0 || p.fifty() {
+				break
+			}
+			move := moves[rnd.Intn(len(moves))]
+			if move.promo() != 0 {
+				promos++
+			}
+			if move.piece().isPawn() && move.to() == p.enpassant && p.enpassant != 0 {
+				enpassants++
+			}
+			p = p.makeMove(move)
+			expect.True(t, p.verifyPawnId())
+		}
+		for node > 0 {
+			p = p.undoLastMove()
+			expect.True(t, p.verifyPawnId())
+		}
+	}
+	expect.True(t, promos > 0)
+	expect.True(t, enpassants > 0)
+}
+
+// Shuffling knights back and forth repeats positions until a pawn move resets
+// the count. Positions from the game history before the root have to repeat
+// three times while second occurrence within the search tree is a draw.
+func TestPositionMoves490(t *testing.T) {
+	defer func() { rootNode = 0 }()
+	p := NewGame().start()
+	expect.Eq(t, p.repetitionCount(), 1)
+
+	shuffle := func() {
+		p = p.makeMove(NewMove(p, G1, F3))
+		p = p.makeMove(NewMove(p, G8, F6))
+		p = p.makeMove(NewMove(p, F3, G1))
+		p = p.makeMove(NewMove(p, F6, G8))
+	}
+	shuffle()
+	expect.Eq(t, p.repetitionCount(), 2)
+
+	rootNode = node // Start the search from repeated initial position.
+	expect.False(t, p.repetition())
+	p = p.makeMove(NewMove(p, G1, F3))
+	p = p.makeMove(NewMove(p, G8, F6))
+	expect.Eq(t, p.repetitionCount(), 2)
+	expect.False(t, p.repetition()) // Repeats the game history once.
+	p = p.makeMove(NewMove(p, F3, G1))
+	p = p.makeMove(NewMove(p, F6, G8))
+	expect.Eq(t, p.repetitionCount(), 3)
+	expect.True(t, p.repetition()) // Repeats the root position.
+	expect.True(t, p.thirdRepetition())
+
+	p = p.makeMove(NewMove(p, E2, E4))
+	expect.Eq(t, p.repetitionCount(), 1)
+	p = p.makeMove(NewMove(p, B8, C6))
+	shuffle()
+	expect.Eq(t, p.repetitionCount(), 2)
+	expect.True(t, p.repetition())
+	expect.False(t, p.thirdRepetition())
+}
@@ -141,7 +141,9 @@ func (p *Position) isAttacked(color int, square int) bool {
 	       (p.bishopMoves(square) & (p.outposts[bishop(color)] | p.outposts[queen(color)])).any()
 }
 
-func (p *Position) pawnTargets(color int, pawns Bitmask) Bitmask {
+// Returns squares attacked by all the given pawns at once. Same as the union of
+// pawnAttacks[color][square] for each pawn but computed with two shifts.
+func pawnAttackSpan(pawns Bitmask, color int) Bitmask {
 	if color == White {
 		return ((pawns & ^maskFile[0]) << 7) | ((pawns & ^maskFile[7]) << 9)
 	}
@@ -150,7 +152,7 @@ func (p *Position) pawnTargets(color int, pawns Bitmask) Bitmask {
 }
 
 func (p *Position) pawnAttacks(color int) Bitmask {
-	return p.pawnTargets(color, p.outposts[pawn(color)])
+	return pawnAttackSpan(p.outposts[pawn(color)], color)
 }
 
 func (p *Position) knightAttacks(color int) (bitmask Bitmask) {
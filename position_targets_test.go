@@ -8,7 +8,7 @@
 
 package donna
 
-import(`github.com/michaeldv/donna/expect`; `testing`)
+import(`github.com/michaeldv/donna/expect`; `math/rand`; `testing`)
 
 // Pawn targets.
 func TestTargets000(t *testing.T) {
@@ -54,3 +54,21 @@ func TestTargets040(t *testing.T) {
 	expect.Eq(t, position.pawnAttacks(White), bit[A4]|bit[B4]|bit[C4]|bit[D4]|bit[E4]|bit[F4]|bit[G4]|bit[H4])
 	expect.Eq(t, position.pawnAttacks(Black), bit[A5]|bit[B5]|bit[C5]|bit[D5]|bit[E5]|bit[F5]|bit[G5]|bit[H5])
 }
+
+// Pawn attack span for random pawn sets matches per-pawn attacks.
+func TestTargets050(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	for i := 0; i < 1000; i++ {
+		pawns := Bitmask(rnd.Uint64() & rnd.Uint64()) & ^(maskRank[0] | maskRank[7])
+		for color := White; color <= Black; color++ {
+			union, advance := Bitmask(0), Bitmask(0)
+			for bm := pawns; bm.any(); bm = bm.pop() {
+				square := bm.first()
+				union |= pawnAttacks[color][square]
+				advance |= maskPassed[color][square] & maskIsolated[col(square)]
+			}
+			expect.Eq(t, pawnAttackSpan(pawns, color), union)
+			expect.Eq(t, pawnAttackSpan(pawns.forward(color), color), advance)
+		}
+	}
+}
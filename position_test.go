@@ -8,7 +8,7 @@
 
 package donna
 
-import(`github.com/michaeldv/donna/expect`; `testing`)
+import(`github.com/michaeldv/donna/expect`; `strings`; `testing`)
 
 // Initial position: castles, no en-passant.
 func TestPosition000(t *testing.T) {
@@ -88,13 +88,64 @@ func TestPosition100(t *testing.T) {
 // Castles, no en-passant.
 func TestPosition110(t *testing.T) {
 	p := NewGame(`2r1kb1r/pp3ppp/2n1b3/1q1N2B1/1P2Q3/8/P4PPP/3RK1NR w Kk - 42 42`).start()
-	expect.Eq(t, p.fen(), `2r1kb1r/pp3ppp/2n1b3/1q1N2B1/1P2Q3/8/P4PPP/3RK1NR w Kk - 42 1`)
+	expect.Eq(t, p.fen(), `2r1kb1r/pp3ppp/2n1b3/1q1N2B1/1P2Q3/8/P4PPP/3RK1NR w Kk - 42 42`)
 }
 
 // No castles, en-passant.
 func TestPosition120(t *testing.T) {
 	p := NewGame(`1rr2k2/p1q5/3p2Q1/3Pp2p/8/1P3P2/1KPRN3/8 w - e6 42 42`).start()
-	expect.Eq(t, p.fen(), `1rr2k2/p1q5/3p2Q1/3Pp2p/8/1P3P2/1KPRN3/8 w - e6 42 1`)
+	expect.Eq(t, p.fen(), `1rr2k2/p1q5/3p2Q1/3Pp2p/8/1P3P2/1KPRN3/8 w - e6 42 42`)
+}
+
+// Truncated FEN: missing half-moves and full moves.
+func TestPosition122(t *testing.T) {
+	p := NewGame(`1rr2k2/p1q5/3p2Q1/3Pp2p/8/1P3P2/1KPRN3/8 w - e6`).start()
+	expect.Eq(t, p.enpassant, E6)
+	expect.Eq(t, p.count50, 0)
+	expect.Eq(t, p.fen(), `1rr2k2/p1q5/3p2Q1/3Pp2p/8/1P3P2/1KPRN3/8 w - e6 0 1`)
+
+	p = NewGame(`2r1kb1r/pp3ppp/2n1b3/1q1N2B1/1P2Q3/8/P4PPP/3RK1NR b Kk - 7`).start()
+	expect.Eq(t, p.color, Black)
+	expect.Eq(t, p.count50, 7)
+	expect.Eq(t, p.fen(), `2r1kb1r/pp3ppp/2n1b3/1q1N2B1/1P2Q3/8/P4PPP/3RK1NR b Kk - 7 1`)
+}
+
+// Truncated FEN: missing castle rights and en-passant square.
+func TestPosition124(t *testing.T) {
+	p := NewGame(`rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w`).start()
+	expect.Eq(t, p.castles, uint8(0))
+	expect.Eq(t, p.enpassant, 0)
+	expect.Eq(t, p.fen(), `rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w - - 0 1`)
+
+	fen := `r3k2r/pp3ppp/8/3pP3/8/8/PP3PPP/R3K2R w KQkq d6 0 1`
+	expect.Eq(t, NewGame(fen).start().fen(), fen)
+}
+
+// Malformed piece placement.
+func TestPosition126(t *testing.T) {
+	expect.True(t, NewGame(`rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP w KQkq - 0 1`).start() == nil)
+	expect.True(t, NewGame(`rnbqkbnr/pppppppp/9/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1`).start() == nil)
+	expect.True(t, NewGame(`rnbqkbnr/ppppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1`).start() == nil)
+	expect.True(t, NewGame(`rnbqkbnr/pppxpppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1`).start() == nil)
+	expect.True(t, NewGame(`rnbq1bnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQ - 0 1`).start() == nil)
+	expect.True(t, NewGame(`rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR x KQkq - 0 1`).start() == nil)
+	expect.True(t, NewGame(`rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR`).start() == nil)
+}
+
+// Full move number gets counted from the initial position.
+func TestPosition128(t *testing.T) {
+	fen := `r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 0 12`
+	p := NewGame(fen).start()
+	expect.Eq(t, p.fen(), fen)
+
+	p = p.makeMove(NewMove(p, F1, B5))
+	expect.Eq(t, p.fen(), `r1bqkbnr/pppp1ppp/2n5/1B2p3/4P3/5N2/PPPP1PPP/RNBQK2R b KQkq - 1 12`)
+	p = p.makeMove(NewMove(p, A7, A6))
+	expect.Eq(t, p.fen(), `r1bqkbnr/1ppp1ppp/p1n5/1B2p3/4P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 0 13`)
+
+	p = NewGame(`r1bqkbnr/pppp1ppp/2n5/1B2p3/4P3/5N2/PPPP1PPP/RNBQK2R b KQkq - 1 12`).start()
+	p = p.makeMove(NewMove(p, A7, A6))
+	expect.Eq(t, p.fen(), `r1bqkbnr/1ppp1ppp/p1n5/1B2p3/4P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 0 13`)
 }
 
 //\\ Donna Chess Format (DCF) tests.
@@ -169,12 +220,30 @@ func TestPosition250(t *testing.T) {
 	expect.True(t, p.insufficient())
 }
 
+// Polyglot keys: reference values from Polyglot book format specification.
+func TestPosition260(t *testing.T) {
+	p := NewGame().start()
+	expect.Eq(t, p.PolyglotKey(), uint64(0x463B96181691FC9C))
+
+	p = NewGame(`rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1`).start()
+	expect.Eq(t, p.PolyglotKey(), uint64(0x823C9B50FD114196)) // E3 can't be captured.
+
+	p = NewGame(`rnbqkbnr/ppp1pppp/8/3pP3/8/8/PPPP1PPP/RNBQKBNR b KQkq - 0 2`).start()
+	expect.Eq(t, p.PolyglotKey(), uint64(0x662FAFB965DB29D4))
+
+	p = NewGame(`rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3`).start()
+	expect.Eq(t, p.PolyglotKey(), uint64(0x22A48B5A8E47FF78)) // E5xF6 is possible.
+
+	p = NewGame(`rnbq1bnr/ppp1pkpp/8/3pPp2/8/8/PPPPKPPP/RNBQ1BNR w - - 0 4`).start()
+	expect.Eq(t, p.PolyglotKey(), uint64(0x00FDD303C946BDD9))
+}
+
 // Restricted mobility for pinned pieces.
 func TestPosition300(t *testing.T) {
 	p := NewGame(`Ka1,a2,Nc3`, `Kh8,h7,Bg8`).start() // Nc3 vs Bishop, no pin.
 	expect.Eq(t, p.Evaluate(), -12)
 	p = NewGame(`Ka1,a2,Nc3`, `Kh8,h7,Bg7`).start() // Nc3 vs Bishop, pin on C3-G7 diagonal.
-	expect.Eq(t, p.Evaluate(), -63)
+	expect.Eq(t, p.Evaluate(), -62)
 
 }
 
@@ -189,3 +258,111 @@ func TestPosition310(t *testing.T) {
 	expect.Eq(t, p.Evaluate(), -319)
 
 }
+
+// Game phase labels over the game history go from opening to endgame.
+func TestPosition320(t *testing.T) {
+	game := NewGame()
+	p := game.start()
+	for _, move := range strings.Split(`e2e4 e7e5 g1f3 b8c6 f1b5 a7a6 b5c6 d7c6 d2d4 e5d4 d1d4 d8d4 f3d4 f8d6 b1c3 g8e7 c1e3 c8d7 e1c1 e8c8 d4f5 e7f5 e4f5 d7f5 d1d6 c7d6`, ` `) {
+		p = p.makeMove(NewMoveFromNotation(p, move))
+	}
+
+	labels := map[string]int{ `opening`: 0, `middlegame`: 1, `endgame`: 2 }
+	previous, last := 256, 0
+	for moveNumber := 1; moveNumber <= 14; moveNumber++ {
+		phase, label := game.PhaseAt(moveNumber)
+		expect.True(t, phase <= previous)
+		expect.True(t, labels[label] >= last)
+		previous, last = phase, labels[label]
+	}
+
+	phase, label := game.PhaseAt(1)
+	expect.Eq(t, phase, 256)
+	expect.Eq(t, label, `opening`)
+	_, label = game.PhaseAt(7)
+	expect.Eq(t, label, `middlegame`)
+	phase, label = game.PhaseAt(14)
+	expect.Eq(t, phase, 90)
+	expect.Eq(t, label, `endgame`)
+	phase, label = game.PhaseAt(100) // Clamped to the last position.
+	expect.Eq(t, phase, 90)
+	expect.Eq(t, label, `endgame`)
+}
+
+// Game phase move numbers follow the initial FEN position's move counter and
+// side to move.
+func TestPosition325(t *testing.T) {
+	game := NewGame(`r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R b KQkq - 5 4`)
+	p := game.start()
+	for _, move := range strings.Split(`f8c5 d1e2 d8e7 e2d1 e7d8 d1e2 c5d4 c2c3 d4c3 b2c3`, ` `) {
+		p = p.makeMove(NewMoveFromNotation(p, move))
+	}
+
+	phase, _ := game.PhaseAt(4)
+	expect.Eq(t, phase, tree[0].Phase())
+	phase, _ = game.PhaseAt(5)
+	expect.Eq(t, phase, tree[1].Phase())
+	phase, _ = game.PhaseAt(7)
+	expect.Eq(t, phase, tree[5].Phase())
+	phase, _ = game.PhaseAt(9)
+	expect.Eq(t, phase, tree[9].Phase())
+	phase, _ = game.PhaseAt(10) // Clamped to the last position.
+	expect.Eq(t, phase, tree[10].Phase())
+	expect.True(t, tree[10].Phase() < tree[9].Phase())
+}
+
+// Changes made to cloned position don't affect the original.
+func TestPosition330(t *testing.T) {
+	p := NewGame(`r3k2r/pppq1ppp/2n1bn2/3pp3/3PP3/2N1BN2/PPPQ1PPP/R3K2R w KQkq d6 0 7`).start()
+	fen, id, pawnId := p.fen(), p.id, p.pawnId
+
+	clone := p.Clone()
+	expect.Eq(t, clone.fen(), fen)
+	expect.Eq(t, clone.id, id)
+
+	clone.pieces[E4], clone.outposts[Pawn], clone.castles, clone.count50 = 0, 0, 0, 42
+	expect.Eq(t, p.fen(), fen)
+	expect.Eq(t, p.id, id)
+	expect.Eq(t, p.outposts[Pawn].count(), 8)
+
+	clone = p.Clone()
+	position := clone
+	for _, move := range strings.Split(`e1c1 e8g8 d4e5 f6g4`, ` `) {
+		position = position.makeMove(NewMoveFromNotation(position, move))
+	}
+	expect.Ne(t, position.fen(), fen)
+	expect.Eq(t, p.fen(), fen)
+	expect.Eq(t, p.id, id)
+	expect.Eq(t, p.pawnId, pawnId)
+	expect.Eq(t, clone.fen(), fen)
+	for node > 0 {
+		position = position.undoLastMove()
+	}
+}
+
+// Zobrist table dump reproduces the keys externally, and is the same from run
+// to run.
+func TestPosition340(t *testing.T) {
+	table := DumpZobrist()
+	expect.Eq(t, len(table), 64 * 12 + 4 + 8 + 1)
+	expect.Eq(t, table[0], uint64(0x9D39247E33776D41))
+	expect.Eq(t, table[780], uint64(0xF8D626AAAF278509))
+
+	checksum := uint64(0)
+	for i, key := range table {
+		checksum ^= key * uint64(i + 1)
+	}
+	expect.Eq(t, checksum, uint64(0x709C4DFD0B3B7B10))
+
+	// Polyglot piece order is p, P, n, N, b, B, r, R, q, Q, k, K.
+	key, board := uint64(0), `rnbqkbnrpppppppp................................PPPPPPPPRNBQKBNR`
+	for i, char := range board {
+		if kind := strings.IndexRune(`pPnNbBrRqQkK`, char); kind >= 0 {
+			key ^= table[64 * kind + square(7 - i / 8, i % 8)]
+		}
+	}
+	for i := 768; i < 772; i++ {
+		key ^= table[i] // All castles are available.
+	}
+	expect.Eq(t, key ^ table[780], NewGame().start().id)
+}
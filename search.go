@@ -22,9 +22,22 @@ func (p *Position) search(alpha, beta, depth int) (score int) {
 		gen.reset()
 	}
 
+	// When searching for extra MultiPV lines the root moves of the lines
+	// found so far are skipped, and the root bookkeeping (cache, history,
+	// refutations) is left alone so that it reflects the main line only.
+	extra := len(game.excluded) > 0
+	refutations := engine.refutations && !extra
+
 	bestAlpha, bestScore := alpha, alpha
 	bestMove, moveCount := Move(0), 0
+	if refutations {
+		game.refutations = game.refutations[:0]
+	}
+
 	for move := gen.nextMove(); move.some(); move = gen.nextMove() {
+		if extra && game.isExcluded(move) {
+			continue
+		}
 		position := p.makeMove(move)
 		moveCount++; game.nodes++
 		if engine.uci {
@@ -39,11 +52,15 @@ func (p *Position) search(alpha, beta, depth int) (score int) {
 		game.deepening = (moveCount == 1)
 		if moveCount == 1 {
 			score = -position.searchTree(-beta, -alpha, newDepth)
+		} else if refutations {
+			// Search every move with full window so that the opponent's
+			// best reply line gets collected.
+			score = -position.searchTree(-Checkmate, Checkmate, newDepth)
 		} else {
 			reduction := 0
-			if !inCheck && !giveCheck && depth > 2 && move.isQuiet() && !move.isKiller(ply) && !move.isPawnAdvance() {
+			if engine.reduceLateMoves() && !inCheck && !giveCheck && depth > 2 && move.isQuiet() && !move.isKiller(ply) && !move.isPawnAdvance() {
 				reduction = lateMoveReductions[(moveCount-1) & 63][depth & 63]
-				if game.history[move.piece()][move.to()] < 0 {
+				if game.good(move) < 0 {
 					reduction++
 				}
 			}
@@ -57,21 +74,26 @@ func (p *Position) search(alpha, beta, depth int) (score int) {
 
 			// If zero window fails then try full window.
 			if score > alpha {
+				game.stats.PvResearches++
 				score = -position.searchTree(-beta, -alpha, newDepth)
 			}
 		}
 		position.undoLastMove()
 
 		// Don't touch anything if the time has elapsed and we need to abort th search.
-		if engine.clock.halt {
+		if engine.halted() {
 			return alpha
 		}
 
+		if refutations {
+			game.saveRefutation(move)
+		}
+
 		if moveCount == 1 || score > alpha {
 			bestMove = move
 			game.saveBest(0, move)
 			gen.scoreMove(depth, score).rearrangeRootMoves()
-			if moveCount > 1 {
+			if moveCount > 1 && !extra {
 				game.volatility++
 			}
 		} else {
@@ -98,13 +120,16 @@ func (p *Position) search(alpha, beta, depth int) (score int) {
 
 
 	if moveCount == 0 {
-		score = let(inCheck, -Checkmate, 0) // Mate if in check, stalemate otherwise.
+		score = let(inCheck, -Checkmate, drawIn(ply)) // Mate if in check, stalemate otherwise.
 		if engine.uci {
 			engine.uciScore(depth, score, alpha, beta)
 		}
 		return score
 	}
 	score = bestScore
+	if extra {
+		return
+	}
 
 	if !inCheck && alpha > bestAlpha {
 		game.saveGood(depth, bestMove).updatePoor(depth, bestMove, gen.reset())
@@ -138,6 +163,14 @@ func (p *Position) Perft(depth int) (total int64) {
 		return 1
 	}
 
+	var entry *PerftEntry
+	if len(perftCache) > 0 {
+		entry = &perftCache[p.id & uint64(len(perftCache) - 1)]
+		if entry.id == p.id && entry.depth == depth {
+			return entry.nodes
+		}
+	}
+
 	gen := NewGen(p, depth).generateAllMoves()
 	for move := gen.nextMove(); move != 0; move = gen.nextMove() {
 		if !move.valid(p, gen.pins) {
@@ -147,5 +180,32 @@ func (p *Position) Perft(depth int) (total int64) {
 		total += position.Perft(depth - 1)
 		position.undoLastMove()
 	}
+
+	if entry != nil {
+		entry.id, entry.depth, entry.nodes = p.id, depth, total
+	}
+	return
+}
+
+// Runs perft for each valid root move and prints the move along with the number
+// of leaf nodes in its subtree. Returns the total number of leaf nodes.
+func (p *Position) Divide(depth int) (total int64) {
+	if depth < 1 {
+		return 1
+	}
+
+	gen := NewGen(p, depth).generateAllMoves()
+	for move := gen.nextMove(); move != 0; move = gen.nextMove() {
+		if !move.valid(p, gen.pins) {
+			continue
+		}
+		position := p.makeMove(move)
+		nodes := position.Perft(depth - 1)
+		position.undoLastMove()
+
+		engine.reply("%s: %d\n", move.notation(), nodes)
+		total += nodes
+	}
+
 	return
 }
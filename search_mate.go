@@ -0,0 +1,111 @@
+// Copyright (c) 2014-2018 by Michael Dvorkin. All Rights Reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+//
+// I am making my contributions/submissions to this project solely in my
+// personal capacity and am not conveying any rights to any intellectual
+// property of any third parties.
+
+package donna
+
+import `time`
+
+// Looks for forced mate in N moves as requested by "go mate N". The search
+// deepens by two plies at a time so that the shortest mate gets found first.
+// Returns the mating move, or no move if there is no mate in N.
+func (game *Game) thinkMate(position *Position, start time.Time) Move {
+	game.getReady()
+
+	for depth := 1; depth <= engine.options.mateIn * 2 - 1; depth += 2 {
+		// Anything below mate in depth plies fails low right away.
+		score := position.searchMate(matingIn(depth) - 1, Checkmate, depth)
+		if engine.halted() {
+			break
+		}
+		if score >= matingIn(depth) {
+			updateRootPv()
+			move := game.rootpv.moves[0]
+			game.score = score
+			engine.publish(move, score, depth)
+			game.printPrincipal(depth, score, position.status(move, score), since(start))
+			game.printBestMove(move, since(start))
+			return move
+		}
+	}
+
+	if engine.uci {
+		engine.reply("info string no mate in %d\nbestmove (none)\n", engine.options.mateIn)
+	}
+
+	return Move(0)
+}
+
+// Mate search. It's full width search that doesn't evaluate positions: the
+// score is either the mate score or zero if the side to move gets away. Since
+// the window is kept around mate scores and mate on the last ply can only be
+// delivered with a check, the lines that can't mate in time get cut off early.
+func (p *Position) searchMate(alpha, beta, depth int) int {
+	ply, inCheck := ply(), p.isInCheck(p.color)
+	game.pv[ply].size = 0
+
+	if p.fifty() || p.insufficient() || p.repetition() {
+		return 0
+	}
+
+	// Checkmate distance pruning.
+	alpha, beta = mateDistance(alpha, beta, ply)
+	if alpha >= beta {
+		return alpha
+	}
+
+	// Ran out of plies: mated if there are no moves, safe otherwise.
+	if depth == 0 {
+		if inCheck && !NewGen(p, ply).generateEvasions().anyValid() {
+			return matedIn(ply)
+		}
+		return 0
+	}
+
+	gen := NewGen(p, ply)
+	if inCheck {
+		gen.generateEvasions().quickRank()
+	} else {
+		gen.generateMoves().rank(Move(0))
+	}
+
+	moveCount := 0
+	for move := gen.nextMove(); move.some(); move = gen.nextMove() {
+		if !move.valid(p, gen.pins) {
+			continue
+		}
+
+		position := p.makeMove(move)
+		moveCount++; game.nodes++
+
+		// The last move either checks or doesn't mate in time.
+		if depth == 1 && !position.isInCheck(position.color) {
+			position.undoLastMove()
+			continue
+		}
+		score := -position.searchMate(-beta, -alpha, depth - 1)
+		position.undoLastMove()
+
+		if engine.halted() {
+			return alpha
+		}
+
+		if score > alpha {
+			alpha = score
+			game.saveBest(ply, move)
+			if alpha >= beta {
+				return alpha
+			}
+		}
+	}
+
+	if moveCount == 0 {
+		return let(inCheck, matedIn(ply), 0)
+	}
+
+	return alpha
+}
@@ -13,13 +13,13 @@ func (p *Position) searchQuiescence(alpha, beta, depth int, inCheck bool) (score
 	ply := ply()
 
 	// Return if it's time to stop search.
-	if ply >= MaxPly || engine.clock.halt {
+	if ply >= MaxPly || engine.halted() {
 		return p.Evaluate()
 	}
 
 	// Insufficient material and repetition/perpetual check pruning.
-	if p.fifty() || p.insufficient() || p.repetition() {
-		return 0
+	if p.fifty() || p.insufficient() || p.insufficientMaterial() || p.repetition() {
+		return drawIn(ply)
 	}
 
 	// Checkmate distance pruning.
@@ -37,6 +37,13 @@ func (p *Position) searchQuiescence(alpha, beta, depth int, inCheck bool) (score
 		game.pv[ply].size = 0 // Reset principal variation.
 	}
 
+	// Stand pat when quiescence search has gone too deep or material has
+	// changed and we're studying fixed material endgame.
+	game.selDepth = max(game.selDepth, ply)
+	if -depth >= engine.quiescenceDepth() || (engine.fixedMaterial && p.balance != tree[rootNode].balance) {
+		return p.Evaluate()
+	}
+
 	// Use fixed depth for caching.
 	newDepth := let(inCheck || depth >= 0, 0, -1)
 
@@ -47,6 +54,7 @@ func (p *Position) searchQuiescence(alpha, beta, depth int, inCheck bool) (score
 		if !isPrincipal && cached.depth() >= newDepth {
 			bounds, score := cached.bounds(), cached.score(ply)
 			if (bounds & cacheBeta != 0 && score >= beta) || (bounds & cacheAlpha != 0 && score <= alpha) {
+				game.stats.CacheCutoffs++
 				return score
 			}
 		}
@@ -83,18 +91,31 @@ func (p *Position) searchQuiescence(alpha, beta, depth int, inCheck bool) (score
 		gen.generateEvasions().quickRank()
 	} else {
 		gen.generateCaptures()
-		if depth == 0 {
+		if depth == 0 && !engine.noQChecks {
 			gen.generateChecks()
 		}
 		gen.rank(cachedMove)
 	}
 
+	// Stand pat score is improving if it's better than the one two plies
+	// ago. When it's not improving delta pruning margin gets tighter.
+	improving := inCheck || node < 2 || tree[node-2].score == Unknown || p.score >= tree[node-2].score
+	margin := pawnUnits(let(improving, 72, 48))
+
 	bestAlpha := alpha
 	bestScore := let(p.score != Unknown, p.score, matedIn(ply))
 	bestMove, moveCount := Move(0), 0
 	for move := gen.nextMove(); move.some(); move = gen.nextMove() {
+		// Skip captures that lose material: the ranking has run static
+		// exchange evaluation on all of them except the cached move.
 		capture := move.capture()
-		if (!inCheck && capture.some() && p.exchange(move) < 0) || !move.valid(p, gen.pins) {
+		if !inCheck && capture.some() && (gen.losing() || (move == cachedMove && p.exchange(move) < 0)) {
+			continue
+		}
+		if !move.valid(p, gen.pins) {
+			continue
+		}
+		if engine.noQPromos && !inCheck && capture.none() && move.isPromo() {
 			continue
 		}
 
@@ -103,15 +124,16 @@ func (p *Position) searchQuiescence(alpha, beta, depth int, inCheck bool) (score
 		giveCheck := position.isInCheck(position.color)
 
 		// Prune useless captures -- but make sure it's not a capture move that checks.
-		if !inCheck && !giveCheck && !isPrincipal && capture != 0 && !move.isPromo() && p.score + pieceValue[capture.id()] + 72 < alpha {
+		if !engine.exactMode && !inCheck && !giveCheck && !isPrincipal && capture != 0 && !move.isPromo() && p.score + pieceValue[capture.id()] + margin < alpha {
 			position.undoLastMove()
+			game.stats.FutilityPrunes++
 			continue
 		}
 		score = -position.searchQuiescence(-beta, -alpha, depth - 1, giveCheck)
 		position.undoLastMove()
 
 		// Don't touch anything if the time has elapsed and we need to abort th search.
-		if engine.clock.halt {
+		if engine.halted() {
 			return alpha
 		}
 
@@ -126,6 +148,7 @@ func (p *Position) searchQuiescence(alpha, beta, depth int, inCheck bool) (score
 					bestMove = move
 				} else {
 					p.cache(move, score, newDepth, ply, cacheBeta)
+					game.saveCutoff(moveCount)
 					return score
 				}
 			}
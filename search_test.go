@@ -8,7 +8,7 @@
 
 package donna
 
-import(`github.com/michaeldv/donna/expect`; `testing`)
+import(`bytes`; `github.com/michaeldv/donna/expect`; `io`; `math/rand`; `strings`; `testing`)
 
 // Mate in 2.
 
@@ -179,6 +179,283 @@ func TestSearch300(t *testing.T) {
 	expect.Eq(t, move, `Ne5-f7`)
 }
 
+// Warm cache with fail low entries shouldn't affect finding the mate.
+func TestSearch310(t *testing.T) {
+	p := NewGame(`Kf8,Re7,Nd5`, `Kh8,Bh5`).start()
+	expect.Eq(t, p.solve(5), `Re7-g7`)
+	expect.Eq(t, p.solve(5), `Re7-g7`)
+}
+
+// Runs fixed depth search with standard output suppressed. Non-zero cache
+// size resizes the game's cache in megabytes keeping its entries.
+func think(depth int, cacheSize float64, clearKillers bool) (Move, SearchStats) {
+	options, output := engine.options, engine.output
+	defer func() { engine.options, engine.output = options, output }()
+
+	if cacheSize > 0 {
+		game.rehashCache(cacheSize)
+	}
+	engine.options.maxDepth, engine.options.clearKillers = depth, clearKillers
+	engine.output = io.Discard
+
+	return game.ThinkWithStats()
+}
+
+// Runs fixed depth search quietly and returns number of nodes searched.
+func thinkNodes(fen string, depth int, clearKillers bool) int {
+	NewGame(fen).start()
+	think(depth, 0, clearKillers)
+
+	return game.nodes + game.qnodes
+}
+
+// Clearing killers between iterations is off by default and makes fixed
+// depth search reproducible. Killers left over from shallower iterations
+// change move ordering, and hence the number of nodes searched.
+func TestSearch320(t *testing.T) {
+	options := engine.options; defer func() { engine.options = options }()
+	expect.False(t, NewEngine().options.clearKillers)
+	expect.True(t, NewEngine(`clearkillers`, true).options.clearKillers)
+	engine = Engine{}
+
+	fen := `r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4`
+	nodes := thinkNodes(fen, 6, true)
+	expect.Eq(t, thinkNodes(fen, 6, true), nodes)
+	expect.Ne(t, thinkNodes(fen, 6, false), nodes)
+}
+
+// Refutation line for the blunder starts with the opponent's winning reply.
+func TestSearch330(t *testing.T) {
+	engine.refutations = true; defer func() { engine.refutations = false }()
+
+	p := NewGame(`Kg1,Qd1,g2,h2`, `Kh8,Nf6,g7,h7`).start()
+	p.solve(3)
+	line := game.refutation(NewMove(p, D1, D5))
+
+	expect.True(t, line.size > 1)
+	expect.Eq(t, line.moves[1].str(), `Nf6xd5`)
+}
+
+// Only move gets played right away.
+func TestSearch340(t *testing.T) {
+	NewGame(`Ka1`, `Kc2,Rh1`).start()
+	move, _ := think(0, 0, false)
+
+	expect.Eq(t, move.str(), `Ka1-a2`)
+	expect.Eq(t, game.nodes, 0)
+}
+
+// Large aspiration delta behaves like full window while small one causes
+// re-searches.
+func TestSearch350(t *testing.T) {
+	defer func() { engine.aspiration = 0 }()
+	fen := `r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4`
+
+	engine.aspiration = Checkmate
+	NewGame(fen).start()
+	think(6, 0, false)
+	expect.Eq(t, game.researches, 0)
+
+	engine.aspiration = 1
+	NewGame(fen).start()
+	think(6, 0, false)
+	expect.True(t, game.researches > 0)
+}
+
+// Search statistics are populated and consistent.
+func TestSearch360(t *testing.T) {
+	NewGame(`r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4`).start()
+	_, stats := think(6, 0.5, false)
+	expect.True(t, stats.QNodes > 0)
+	expect.True(t, stats.QNodes <= stats.Nodes)
+	expect.True(t, stats.CacheStores > 0)
+	expect.True(t, stats.CacheCutoffs <= stats.CacheHits)
+
+	cutoffs := 0
+	for _, count := range stats.Cutoffs {
+		cutoffs += count
+	}
+	expect.Eq(t, cutoffs, stats.BetaCutoffs)
+	expect.True(t, stats.Cutoffs[0] > stats.BetaCutoffs / 2) // Decent move ordering.
+
+	// Statistics get reset for each search.
+	_, again := think(6, 0.5, false)
+	expect.True(t, again.Nodes < stats.Nodes * 2)
+}
+
+// Quiescence search stops at the depth limit in a long capture chain.
+func TestSearch370(t *testing.T) {
+	defer func() { engine.maxQDepth = 0 }()
+	chain := []string{ `Kg1,Qd1,Rd2,Rd3,Nc3,Nf4,Bg2,c4,e4`, `M,Kg8,Qd6,Rd7,Rd8,Nb6,Nf6,Bc6,d5,e6` }
+
+	p := NewGame(chain...).start()
+	p.searchQuiescence(-Checkmate, Checkmate, 0, false)
+	expect.True(t, game.selDepth > 4)
+
+	engine.maxQDepth = 4
+	p = NewGame(chain...).start()
+	p.searchQuiescence(-Checkmate, Checkmate, 0, false)
+	expect.Eq(t, game.selDepth, 4)
+}
+
+// Best line in standard algebraic notation ends with checkmate.
+func TestSearch380(t *testing.T) {
+	engine.output = io.Discard; defer func() { engine.output = nil }()
+
+	p := NewGame(`Kg1,Ra1,Rb2`, `Kh8`).start()
+	line, score := game.BestLineSAN(SearchLimits{ Depth: 5 })
+
+	expect.True(t, game.position() == p) // Scratch moves are taken back.
+	expect.Eq(t, len(line), 3)
+	expect.True(t, strings.HasSuffix(line[len(line) - 1], `#`))
+	expect.True(t, isMate(score))
+}
+
+// Fixed material mode doesn't search past the capture that changes material.
+func TestSearch390(t *testing.T) {
+	p := NewGame(`Kg1,Ra1,h2`, `Kh7,Qa8,g7`).start()
+	expect.Eq(t, p.solve(4), `Ra1xa8`)
+	expect.True(t, game.pv[0].size > 1)
+
+	engine.fixedMaterial = true; defer func() { engine.fixedMaterial = false }()
+	p = NewGame(`Kg1,Ra1,h2`, `Kh7,Qa8,g7`).start()
+	expect.Eq(t, p.solve(4), `Ra1xa8`)
+	expect.Eq(t, game.pv[0].size, 1)
+}
+
+// Resign callback fires once after required number of bad scores in a row.
+func TestSearch395(t *testing.T) {
+	resigned := 0
+	NewEngine(`resign`, 800, `resignmoves`, 3, `onresign`, func() { resigned++ })
+	defer func() { engine = Engine{} }()
+
+	NewGame()
+	for _, score := range []int{ -900, -900, -100, -900, -900 } {
+		expect.False(t, game.adjudicate(score))
+	}
+	expect.Eq(t, resigned, 0)
+
+	expect.True(t, game.adjudicate(-1000))
+	expect.False(t, game.adjudicate(-1000))
+	expect.Eq(t, resigned, 1)
+}
+
+// Search results don't depend on move order: shuffling moves with different
+// seeds changes the number of nodes searched but not the best move and score.
+func TestSearch396(t *testing.T) {
+	engine.exactMode = true; defer func() { engine.exactMode = false }()
+	defer func() { engine.shuffle = 0 }()
+
+	fen := `r2qkb1r/ppp2ppp/2n5/3np3/2B5/5Q2/PPPP1PPP/RNB1K2R w KQkq - 0 7`
+	NewGame(fen).start()
+	move, stats := think(4, 0, false)
+	score := game.score
+
+	nodes := map[int]bool{ stats.Nodes: true }
+	for _, seed := range []int64{ 1, 42, 0x5EED } {
+		engine.shuffle = seed
+		NewGame(fen).start()
+		shuffled, stats := think(4, 0, false)
+		expect.Eq(t, shuffled, move)
+		expect.Eq(t, game.score, score)
+		nodes[stats.Nodes] = true
+	}
+	expect.True(t, len(nodes) > 1)
+}
+
+// Quiescence search finds winning capture sequence.
+func TestSearch397(t *testing.T) {
+	p := NewGame(`Kg1,Rd1,Rd2,a2`, `Kg8,Nd5,Bb7,a7`).start()
+	score := p.searchQuiescence(-Checkmate, Checkmate, 0, false)
+	expect.True(t, score > p.Evaluate() + onePawn)
+}
+
+// Transposition table carries over when stepping through the game move by
+// move so that search on the next position reuses the previous analysis.
+func TestSearch398(t *testing.T) {
+	moves := []string{ `d2d4`, `d7d5`, `c2c4`, `e7e6`, `b1c3`, `g8f6` }
+	play := func(game *Game, moves []string) {
+		position := game.start()
+		for _, move := range moves {
+			position = position.makeMove(NewMoveFromNotation(position, move))
+		}
+	}
+
+	var stats SearchStats
+	game := NewGame()
+	for i := 1; i <= len(moves); i++ {
+		play(game, moves[:i])
+		_, stats = think(6, 2, false)
+	}
+
+	game = NewGame()
+	play(game, moves)
+	_, fresh := think(6, 2, false)
+
+	expect.True(t, stats.CacheCutoffs > fresh.CacheCutoffs)
+	expect.True(t, stats.Nodes < fresh.Nodes)
+}
+
+// Plain alpha-beta search without any pruning or reductions to verify exact
+// mode against. Mirrors search extensions and quiescence of searchTree().
+func fullSearch(p *Position, alpha, beta, depth int) int {
+	if p.fifty() || p.insufficient() || p.repetition() {
+		return 0
+	}
+
+	inCheck := p.isInCheck(p.color)
+	if !inCheck && depth < 1 {
+		return p.searchQuiescence(alpha, beta, 0, inCheck)
+	}
+
+	gen := NewGen(p, ply())
+	if inCheck {
+		gen.generateEvasions()
+	} else {
+		gen.generateMoves()
+	}
+
+	moveCount := 0
+	for move := gen.nextMove(); move.some(); move = gen.nextMove() {
+		if !move.valid(p, gen.pins) {
+			continue
+		}
+		position := p.makeMove(move)
+		moveCount++
+		giveCheck := position.isInCheck(position.color)
+		newDepth := let(giveCheck && p.exchange(move) >= 0, depth, depth - 1)
+		score := -fullSearch(position, -beta, -alpha, newDepth)
+		position.undoLastMove()
+
+		if score > alpha {
+			if score >= beta {
+				return score
+			}
+			alpha = score
+		}
+	}
+
+	if moveCount == 0 {
+		return let(inCheck, matedIn(ply()), 0)
+	}
+
+	return alpha
+}
+
+// Exact mode disables pruning so the score matches plain alpha-beta search.
+func TestSearch399(t *testing.T) {
+	engine.exactMode = true; defer func() { engine.exactMode = false }()
+
+	p := NewGame(`Kg1,Qd1,Rf1,Bb3,Nf3,a2,b2,c3,f2,g2,h2`, `Kg8,Qd8,Rf8,Bc8,Nd7,a7,b7,c6,f7,g7,h7`).start()
+	score := p.searchTree(-Checkmate, Checkmate, 3)
+	expect.Eq(t, game.stats.NullCutoffs, 0)
+	expect.Eq(t, game.stats.FutilityPrunes, 0)
+	expect.Eq(t, game.stats.Researches, 0)
+
+	p = NewGame(`Kg1,Qd1,Rf1,Bb3,Nf3,a2,b2,c3,f2,g2,h2`, `Kg8,Qd8,Rf8,Bc8,Nd7,a7,b7,c6,f7,g7,h7`).start()
+	expect.Eq(t, fullSearch(p, -Checkmate, Checkmate, 3), score)
+}
+
 // Perft.
 func TestSearch400(t *testing.T) {
 	position := NewGame().start()
@@ -209,3 +486,438 @@ func TestSearch450(t *testing.T) {
 	position := NewGame().start()
 	expect.Eq(t, position.Perft(5), int64(4865609))
 }
+
+// Perft with cache.
+func TestSearch460(t *testing.T) {
+	NewPerftCache(16); defer NewPerftCache(0)
+	position := NewGame().start()
+	expect.Eq(t, position.Perft(5), int64(4865609))
+	expect.Eq(t, position.Perft(5), int64(4865609)) // Warm cache.
+}
+
+func TestSearch470(t *testing.T) { // Kiwipete.
+	position := NewGame(`r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1`).start()
+	uncached := position.Perft(3)
+
+	NewPerftCache(16); defer NewPerftCache(0)
+	expect.Eq(t, position.Perft(3), uncached)
+	expect.Eq(t, uncached, int64(97862))
+}
+
+func TestSearch480(t *testing.T) { // Kiwipete.
+	position := NewGame(`r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1`).start()
+	NewPerftCache(64); defer NewPerftCache(0)
+	expect.Eq(t, position.Perft(4), int64(4085603))
+	expect.Eq(t, position.Perft(5), int64(193690690))
+}
+
+// Divide prints subtree node counts for each root move.
+func TestSearch490(t *testing.T) {
+	var output bytes.Buffer
+	engine.output = &output; defer func() { engine.output = nil }()
+
+	position := NewGame(`r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1`).start()
+	expect.Eq(t, position.Divide(2), int64(2039))
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	expect.Eq(t, len(lines), 48)
+	expect.True(t, strings.Contains(output.String(), "e1g1: 43\n")) // Castle.
+	expect.True(t, strings.Contains(output.String(), "d5e6: 46\n"))
+}
+
+// Node count for repeated search with warm cache.
+func BenchmarkSearch000(b *testing.B) {
+	p := NewGame(`r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4`).start()
+	for i := 0; i < b.N; i++ {
+		game.nodes, game.qnodes = 0, 0
+		p.solve(6)
+	}
+	b.ReportMetric(float64(game.nodes + game.qnodes), `nodes/op`)
+}
+
+// Node count for quiescence search of a long capture chain.
+func BenchmarkSearch010(b *testing.B) {
+	p := NewGame(`Kg1,Qd1,Rd2,Rd3,Nc3,Nf4,Bg2,c4,e4`, `M,Kg8,Qd6,Rd7,Rd8,Nb6,Nf6,Bc6,d5,e6`).start()
+	for i := 0; i < b.N; i++ {
+		game.nodes, game.qnodes = 0, 0
+		p.searchQuiescence(-Checkmate, Checkmate, 0, false)
+	}
+	b.ReportMetric(float64(game.qnodes), `nodes/op`)
+}
+
+// Perft without and with cache.
+func BenchmarkSearch020(b *testing.B) {
+	position := NewGame().start()
+	for i := 0; i < b.N; i++ {
+		position.Perft(4)
+	}
+}
+
+func BenchmarkSearch030(b *testing.B) {
+	position := NewGame().start()
+	for i := 0; i < b.N; i++ {
+		NewPerftCache(1)
+		position.Perft(4)
+	}
+	NewPerftCache(0)
+}
+
+// Quiescence search of tactical positions.
+func BenchmarkSearch040(b *testing.B) {
+	positions := []string{
+		`r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4`,
+		`r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1`,
+		`2r3k1/pp3ppp/2n1b3/q2pP3/3P4/P1PB1N2/5PPP/R2Q2K1 b - - 0 1`,
+	}
+	for i := 0; i < b.N; i++ {
+		for _, fen := range positions {
+			NewGame(fen).start().searchQuiescence(-Checkmate, Checkmate, 0, false)
+		}
+	}
+}
+
+// Fuzz test: plays random legal moves from the initial position evaluating
+// and searching along the way. Each game is reproducible given its seed.
+func TestSearch500(t *testing.T) {
+	for seed := int64(1); seed <= 16; seed++ {
+		fuzzGame(t, seed, 48)
+	}
+}
+
+func fuzzGame(t *testing.T, seed int64, plies int) {
+	moves := []string{}
+	fail := func(format string, args ...interface{}) {
+		t.Fatalf("seed %d, moves %s: " + format, append([]interface{}{ seed, strings.Join(moves, ` `) }, args...)...)
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			fail(`panic: %v`, err)
+		}
+	}()
+
+	rnd := rand.New(rand.NewSource(seed))
+	p := NewGame().start()
+	for i := 0; i < plies; i++ {
+		legal := NewMoveGen(p).generateAllMoves().validOnly().allMoves()
+		if len(legal) == 0 {
+			break
+		}
+
+		// Make and unmake all legal moves making sure the position is intact.
+		before := *p
+		for _, move := range legal {
+			p.makeMove(move).undoLastMove()
+			if *p != before {
+				fail(`make/unmake %s changed the position`, move)
+			}
+		}
+
+		if score := p.Evaluate(); abs(score) >= Checkmate {
+			fail(`invalid evaluation score %d`, score)
+		}
+
+		// Run shallow search every few moves and make sure it comes up
+		// with a legal move.
+		if i % 8 == 7 {
+			rootNode = node
+			best := p.solve(2)
+			if !NewMoveGen(p).generateAllMoves().validOnly().amongValid(best) {
+				fail(`search returned invalid move %s`, best)
+			}
+			if before.score = p.score; *p != before {
+				fail(`search changed the position`)
+			}
+		}
+
+		move := legal[rnd.Intn(len(legal))]
+		moves = append(moves, move.str())
+		p = p.makeMove(move)
+	}
+}
+
+// MultiPV search reports distinct lines and leaves the main line alone.
+func TestSearch510(t *testing.T) {
+	defer func() { engine.multiPV = 0 }()
+
+	fen := `r2qkb1r/ppp2ppp/2n5/3np3/2B5/5Q2/PPPP1PPP/RNB1K2R w KQkq - 0 7`
+	NewGame(fen).start()
+	move, stats := think(5, 2, false)
+
+	engine.multiPV = 1
+	NewGame(fen).start()
+	single, singleStats := think(5, 2, false)
+	expect.Eq(t, single, move)
+	expect.Eq(t, singleStats.Nodes, stats.Nodes)
+	expect.Eq(t, len(game.multipv), 0)
+
+	engine.multiPV = 3
+	NewGame(fen).start()
+	multi, _ := think(5, 2, false)
+	expect.Eq(t, multi, move)
+	expect.Eq(t, len(game.multipv), 2)
+	first, second := game.multipv[0].moves[0], game.multipv[1].moves[0]
+	expect.True(t, first != move && second != move && first != second)
+	expect.Eq(t, len(game.excluded), 0)
+}
+
+// Limited strength play picks the best move unless enabled, and with low
+// elo rating occasionally settles for weaker moves.
+func TestSearch520(t *testing.T) {
+	defer func() { engine.limitStrength, engine.elo = false, 0 }()
+	defer SetSeed(defaultSeed)
+
+	play := func() Move {
+		NewGame().start()
+		move, _ := think(4, 2, false)
+		return move
+	}
+	best := play()
+
+	engine.elo = minElo
+	expect.Eq(t, play(), best)
+
+	engine.limitStrength = true
+	weaker := 0
+	for seed := uint64(1); seed <= 8; seed++ {
+		SetSeed(seed)
+		if play() != best {
+			weaker++
+		}
+	}
+	expect.True(t, weaker > 0)
+
+	engine.elo = maxElo
+	expect.Eq(t, play(), best)
+}
+
+// Chess960 perft.
+func TestSearch530(t *testing.T) {
+	engine.chess960 = true; defer func() { engine.chess960 = false; NewGame().start() }()
+	p := NewGame(`bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9`).start()
+	expect.Eq(t, p.Perft(3), int64(12189))
+	expect.Eq(t, p.Perft(4), int64(326672))
+}
+
+// Pruning margins scale with piece values, and the search keeps finding
+// tactics with the scaled margins.
+func TestSearch540(t *testing.T) {
+	razoring, futility := razoringMargin(2), futilityMargin(3)
+	values := []*Score{ &valuePawn, &valueKnight, &valueBishop, &valueRook, &valueQueen }
+	saved, victims := []Score{}, pieceValue
+	for _, value := range values {
+		saved = append(saved, *value)
+		value.midgame, value.endgame = value.midgame * 2, value.endgame * 2
+	}
+	for i := range pieceValue {
+		pieceValue[i] *= 2
+	}
+	defer func() {
+		for i, value := range values {
+			*value = saved[i]
+		}
+		pieceValue = victims
+	}()
+
+	expect.Eq(t, razoringMargin(2), razoring * 2)
+	expect.Eq(t, futilityMargin(3), futility * 2)
+	expect.Eq(t, NewGame(`Kf8,Rh1,g6`, `Kh8,Bg8,g7,h7`).start().solve(3), `Rh1-h6`)
+	expect.Eq(t, NewGame(`Kg1,Ra1,h2`, `Kh7,Qa8,g7`).start().solve(4), `Ra1xa8`)
+	expect.Eq(t, NewGame(`Kf4,Qc2,Nc5`, `Kd4`).start().solve(3), `Nc5-b7`)
+}
+
+// Tie-break policy picks among near-equal root moves: 2.exd5 and 2.e5 score
+// within the margin after 1.e4 d5.
+func TestSearch550(t *testing.T) {
+	defer func() { engine.tieBreak = tieBreakNone }()
+
+	play := func(policy int) Move {
+		engine.tieBreak = policy
+		NewGame(`rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 2`).start()
+		move, _ := think(6, 16, false)
+		return move
+	}
+
+	expect.Eq(t, play(tieBreakNone).str(), `e4xd5`)
+	expect.Eq(t, play(tieBreakTactical).str(), `e4xd5`)
+	expect.Eq(t, play(tieBreakPositional).str(), `e4-e5`)
+}
+
+// Late move reductions don't hide tactical shots from "Win at Chess" suite
+// at fixed depth, and the reduced search visits fewer nodes.
+func TestSearch560(t *testing.T) {
+	cacheSize := engine.cacheSize; defer func() { engine.cacheSize = cacheSize }()
+	engine.cacheSize = 16
+	defer func() { engine.noReductions = false }()
+
+	positions := []struct{ white, black, best string }{
+		{ `Kg1,Qg3,Ra1,Rf1,Bc2,Ne5,Nf6,a2,b2,h2,c3,d4`, `Kh8,Qd6,Rc8,Rd8,Be6,Nb6,Nc6,c4,d5,h6,a7,b7,f7,g7`, `Qg3-g6` },
+		{ `Kg1,Qe2,Rf1,Re3,Bd2,Nh1,a2,b2,g2,c3,h3,d4`, `Kg8,Qg5,Rh4,Rf8,Bd6,Bd7,f4,a6,c6,b7,c7,h7`, `Re3-g3` },
+		{ `Kh6,Rb6,g3`, `Kh8,Rg5,g4,a7`, `Rb6-b7` },
+		{ `Kg1,Qg6,Rd1,Rh4,Nd6,b2,f2,g2,h2,e3,a4,e5`, `Kg8,Qc7,Rg7,Rd8,Bc8,Nh7,b4,c5,a6,e6,f6`, `Rh4xh7` },
+		{ `Kh1,Qf1,Ra1,Bg2,Nc5,a2,e2,h2,g3`, `Kg8,Qe5,Rf8,Nc6,c4,d5,e6,h6,a7,b7,g7`, `Qf1xf8` },
+		{ `Kg1,Qd2,Re1,Bd3,Nf5,h2,a3,f3,g3,b4`, `Kg8,Qc3,Rf8,Bb7,Nd5,a6,b6,f6,f7,h7`, `Qd2-h6` },
+		{ `Kg1,Qc1,Rd1,Re1,Bg2,Ne4,a2,e2,f2,h2,g3,b4`, `Kg7,Qe7,Rb8,Rf8,Ba6,Nc4,a4,f5,c6,d6,g6,h7`, `Ne4xd6` },
+		{ `Kg2,Qc3,Rd2,a2,f2,h2,g3`, `Ke8,Qe1,Rg7,Bc5,b6,a7,f7,h7`, `Rd2-d8` },
+	}
+
+	nodes := [2]int{}
+	for i, noReductions := range []bool{ false, true } {
+		engine.noReductions = noReductions
+		for _, position := range positions {
+			move := NewGame(position.white, position.black).start().solve(6)
+			expect.Eq(t, move.str(), position.best)
+			nodes[i] += game.nodes
+		}
+	}
+	expect.True(t, nodes[0] < nodes[1])
+}
+
+// With the fifty moves counter at 99 any quiet piece move draws. Without
+// contempt the engine takes the draw in the equal position, and positive
+// contempt makes it play on with a pawn move.
+func TestSearch570(t *testing.T) {
+	defer func() { engine.contempt = 0 }()
+
+	draws := func(contempt int) bool {
+		engine.contempt = contempt
+		p := NewGame(`6k1/pp3ppp/2n5/8/8/2N5/PP3PPP/6K1 w - - 99 60`).start()
+		move, _ := think(6, 16, false)
+		return p.makeMove(move).fifty()
+	}
+
+	expect.True(t, draws(0))
+	expect.True(t, draws(-50))
+	expect.False(t, draws(20))
+	expect.False(t, draws(50))
+}
+
+// Changing contempt between two searches of the same dead drawn position
+// changes the draw score even though the position stays the same. Static
+// evaluation doesn't depend on contempt.
+func TestSearch575(t *testing.T) {
+	defer func() { engine.contempt = 0 }()
+
+	eval := NewGame(`Kg1,Nb1,e2`, `Kg8,d7`).start().Evaluate()
+	game := NewGame(`Kg1,Nb1`, `Kg8`)
+	p := game.start(); think(4, 16, false)
+	expect.Eq(t, game.score, 0)
+	expect.Eq(t, p.Evaluate(), DrawScore)
+
+	engine.contempt = 30
+	p = game.start(); think(4, 16, false)
+	expect.Eq(t, game.score, -30)
+	expect.Eq(t, p.Evaluate(), DrawScore)
+	expect.Eq(t, NewGame(`Kg1,Nb1,e2`, `Kg8,d7`).start().Evaluate(), eval)
+}
+
+// Queen that is way behind in material gives perpetual check.
+func TestSearch580(t *testing.T) {
+	cacheSize := engine.cacheSize; defer func() { engine.cacheSize = cacheSize }()
+	engine.cacheSize = 16
+
+	p := NewGame(`Kg3,Qf7`, `Kh8,Qc2,Rd1,g6,h6`).start()
+	NewRootGen(p, 1).generateRootMoves()
+	expect.Eq(t, p.search(-Checkmate, Checkmate, 6), 0)
+
+	pv := game.pv[0]
+	expect.True(t, pv.size >= 4)
+	for i := 0; i < pv.size; i += 2 {
+		p = p.makeMove(pv.moves[i])
+		expect.True(t, p.isInCheck(Black))
+		if i + 1 < pv.size {
+			p = p.makeMove(pv.moves[i+1])
+		}
+	}
+}
+
+// Quiet mating move is ordered late so its zero window search fails high at
+// the PV node. The full window re-search must update the PV and return exact
+// score.
+func TestSearch590(t *testing.T) {
+	engine.exactMode = true; defer func() { engine.exactMode = false }()
+
+	p := NewGame(`Kf2,Qf7,Nf3`, `Kg4`).start()
+	score := p.searchTree(-Checkmate, Checkmate, 3)
+	expect.True(t, game.stats.PvResearches > 0)
+	expect.Eq(t, score, Checkmate - 3)
+	expect.Eq(t, game.pv[0].moves[0].str(), `Qf7-f6`)
+
+	p = NewGame(`Kf2,Qf7,Nf3`, `Kg4`).start()
+	expect.Eq(t, fullSearch(p, -Checkmate, Checkmate, 3), score)
+}
+
+// Turning off checks in quiescence search reduces the number of nodes in a
+// quiet position while the score stays about the same.
+func TestSearch600(t *testing.T) {
+	defer func() { engine.noQChecks = false }()
+	fen := `r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4`
+
+	p := NewGame(fen).start()
+	NewRootGen(p, 1).generateRootMoves()
+	checks := p.search(-Checkmate, Checkmate, 4)
+	qnodes := game.qnodes
+
+	engine.noQChecks = true
+	p = NewGame(fen).start()
+	NewRootGen(p, 1).generateRootMoves()
+	score := p.search(-Checkmate, Checkmate, 4)
+
+	expect.True(t, game.qnodes < qnodes)
+	expect.True(t, abs(score - checks) <= onePawn / 4)
+}
+
+// Killer moves found at one node get tried first at sibling nodes of the same
+// ply, followed by quiet moves sorted by history. History gets aged rather
+// than cleared between searches.
+func TestSearch610(t *testing.T) {
+	p := NewGame().start()
+	game.getReady()
+
+	position := p.makeMove(NewMoveFromNotation(p, `e2e4`))
+	game.saveGood(4, NewMoveFromNotation(position, `g8f6`))
+	game.saveGood(4, NewMoveFromNotation(position, `b8c6`))
+	position.undoLastMove()
+
+	position = p.makeMove(NewMoveFromNotation(p, `d2d4`))
+	game.history[A7][A6] = 100
+	gen := NewGen(position, ply()).generateMoves().rank(Move(0))
+	expect.Eq(t, gen.nextMove().notation(), `b8c6`)
+	expect.Eq(t, gen.nextMove().notation(), `g8f6`)
+	expect.Eq(t, gen.nextMove().notation(), `a7a6`)
+	position.undoLastMove()
+
+	game.getReady()
+	expect.Eq(t, game.history[A7][A6], 25)
+	expect.Eq(t, game.killers[1], [2]Move{})
+}
+
+// No null move pruning with king and pawns only: in blocked pawn endgame the
+// side to move is often in zugzwang, so the score must match the search
+// without null moves.
+func TestSearch620(t *testing.T) {
+	defer func() { engine.noNullMove = false }()
+	fen := `8/8/1p1k4/1P1p4/3P4/3K4/8/8 w - - 0 1`
+
+	p := NewGame(fen).start()
+	NewRootGen(p, 1).generateRootMoves()
+	score := p.search(-Checkmate, Checkmate, 8)
+	expect.Eq(t, game.stats.NullCutoffs, 0)
+
+	engine.noNullMove = true
+	p = NewGame(fen).start()
+	NewRootGen(p, 1).generateRootMoves()
+	expect.Eq(t, p.search(-Checkmate, Checkmate, 8), score)
+
+	// Toggle turns null move pruning off in the middle game.
+	fen = `r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4`
+	p = NewGame(fen).start()
+	NewRootGen(p, 1).generateRootMoves()
+	p.search(-Checkmate, Checkmate, 6)
+	expect.Eq(t, game.stats.NullCutoffs, 0)
+
+	engine.noNullMove = false
+	p = NewGame(fen).start()
+	NewRootGen(p, 1).generateRootMoves()
+	p.search(-Checkmate, Checkmate, 6)
+	expect.True(t, game.stats.NullCutoffs > 0)
+}
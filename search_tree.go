@@ -12,16 +12,28 @@ func (p *Position) searchTree(alpha, beta, depth int) (score int) {
 	ply := ply()
 
 	// Return if it's time to stop search.
-	if ply >= MaxPly || engine.clock.halt {
+	if ply >= MaxPly || engine.halted() {
 		return p.Evaluate()
 	}
 
+	// Periodically report search progress.
+	if engine.uci && game.nodes & 1023 == 0 {
+		game.reportProgress()
+	}
+
 	// Reset principal variation.
 	game.pv[ply].size = 0
+	game.selDepth = max(game.selDepth, ply)
+
+	// Stop the line if material has changed and we're studying fixed
+	// material endgame.
+	if engine.fixedMaterial && p.balance != tree[rootNode].balance {
+		return p.Evaluate()
+	}
 
 	// Insufficient material and repetition/perpetual check pruning.
-	if p.fifty() || p.insufficient() || p.repetition() {
-		return 0
+	if p.fifty() || p.insufficient() || p.insufficientMaterial() || p.repetition() {
+		return drawIn(ply)
 	}
 
 	// Checkmate distance pruning.
@@ -30,13 +42,18 @@ func (p *Position) searchTree(alpha, beta, depth int) (score int) {
 		return alpha
 	}
 
+	// Tablebase hit gives the exact score.
+	if score, ok := p.probeTablebase(); ok {
+		return score
+	}
+
 	// Initialize node search conditions.
 	isNull := p.isNull()
 	inCheck := p.isInCheck(p.color)
 	isPrincipal := (beta - alpha > 1)
 
 	// Probe cache.
-	cached, cachedMove := p.probeCache(), Move(0)
+	cached, cachedMove, failLow := p.probeCache(), Move(0), false
 	if cached != nil {
 		cachedMove = cached.move
 		if !isPrincipal && cached.depth() >= depth {
@@ -45,9 +62,14 @@ func (p *Position) searchTree(alpha, beta, depth int) (score int) {
 				if score >= beta && !inCheck && cachedMove.some() {
 					game.saveGood(depth, cachedMove)
 				}
+				game.stats.CacheCutoffs++
 				return score
 			}
 		}
+
+		// Upper bound from slightly shallower search that is below alpha
+		// suggests the node is likely to fail low.
+		failLow = cached.bounds() == cacheAlpha && cached.depth() >= depth - 2 && cached.score(ply) <= alpha
 	}
 
 	if !inCheck {
@@ -69,15 +91,11 @@ func (p *Position) searchTree(alpha, beta, depth int) (score int) {
 		}
 	}
 
-	// Razoring and futility margin pruning.
-	if !inCheck && !isPrincipal {
+	// Razoring and futility margin pruning, unless we're doing exact analysis.
+	if !inCheck && !isPrincipal && !engine.exactMode {
 
 		// No razoring if pawns are on 7th rank.
 		if cachedMove.null() && depth < 3 && p.outposts[pawn(p.color)] & mask7th[p.color] == 0 {
-			razoringMargin := func(depth int) int {
-				return 96 + 64 * (depth - 1)
-			}
-
 		   	// Special case for razoring at low depths.
 			if p.score <= alpha - razoringMargin(5) {
 				return p.searchQuiescence(alpha, beta, 0, inCheck)
@@ -94,19 +112,33 @@ func (p *Position) searchTree(alpha, beta, depth int) (score int) {
 		if !isNull && depth < 14 && !isMate(beta) &&
 		   (p.outposts[p.color] & ^(p.outposts[king(p.color)] | p.outposts[pawn(p.color)])).any() {
 			// Largest conceivable positional gain.
-			if gain := p.score - 256 * depth; gain >= beta {
+			if gain := p.score - futilityMargin(depth); gain >= beta {
+				game.stats.FutilityPrunes++
 				return gain
 			}
 		}
 
-		// Null move pruning.
-		if !isNull && depth > 1 && p.outposts[p.color].count() > 5 {
-			position := p.makeNullMove()
+		// Null move pruning. Skip it if we only have king and pawns since
+		// zugzwang is likely, and verify the cutoff near the horizon with
+		// shallow search that doesn't allow null moves.
+		if engine.nullMovePruning() && !isNull && !game.verifying && depth > 1 && p.outposts[p.color].count() > 5 &&
+		   (p.outposts[p.color] & ^(p.outposts[king(p.color)] | p.outposts[pawn(p.color)])).any() {
+			position := p.makeNull()
 			game.nodes++
 			nullScore := -position.searchTree(-beta, -beta + 1, depth - 1 - 3)
-			position.undoLastMove()
+			position.unmakeNull()
+
+			if nullScore >= beta && depth <= 6 {
+				game.verifying = true
+				score := p.searchTree(beta - 1, beta, max(1, depth - 1 - 3))
+				game.verifying = false
+				if score < beta {
+					nullScore = score
+				}
+			}
 
 			if nullScore >= beta {
+				game.stats.NullCutoffs++
 				if isMate(nullScore) {
 					return beta
 				}
@@ -133,6 +165,9 @@ func (p *Position) searchTree(alpha, beta, depth int) (score int) {
 	} else {
 		gen.generateMoves().rank(cachedMove)
 	}
+	if engine.shuffle != 0 {
+		gen.shuffle(engine.shuffle ^ int64(p.id))
+	}
 
 	bestScore := alpha
 	bestMove, moveCount := Move(0), 0
@@ -153,7 +188,7 @@ func (p *Position) searchTree(alpha, beta, depth int) (score int) {
 			score = -position.searchTree(-beta, -alpha, newDepth)
 		} else {
 			reduction := 0
-			if !inCheck && !giveCheck && depth > 2 && move.isQuiet() && !move.isKiller(ply) && !move.isPawnAdvance() {
+			if engine.reduceLateMoves() && !inCheck && !giveCheck && depth > 2 && move.isQuiet() && !move.isKiller(ply) && !move.isPawnAdvance() {
 				reduction = lateMoveReductions[(moveCount-1) & 63][depth & 63]
 				if isPrincipal {
 					reduction /= 2
@@ -163,7 +198,11 @@ func (p *Position) searchTree(alpha, beta, depth int) (score int) {
 						reduction++
 					}
 					// Reduce more for weak queit moves.
-					if move.isQuiet() && game.history[move.piece()][move.to()] < 0 {
+					if move.isQuiet() && game.good(move) < 0 {
+						reduction++
+					}
+					// Reduce more if cache entry suggests the node fails low.
+					if failLow && reduction > 0 && move != cachedMove {
 						reduction++
 					}
 				}
@@ -173,18 +212,20 @@ func (p *Position) searchTree(alpha, beta, depth int) (score int) {
 
 			// Verify late move reduction and re-run the search if necessary.
 			if reduction > 0 && score > alpha {
+				game.stats.Researches++
 				score = -position.searchTree(-alpha - 1, -alpha, newDepth)
 			}
 
 			// If zero window failed try full window.
 			if isPrincipal && score > alpha && score < beta {
+				game.stats.PvResearches++
 				score = -position.searchTree(-beta, -alpha, newDepth)
 			}
 		}
 		position.undoLastMove()
 
 		// Don't touch anything if the time has elapsed and we need to abort th search.
-		if engine.clock.halt {
+		if engine.halted() {
 			return alpha
 		}
 
@@ -199,6 +240,10 @@ func (p *Position) searchTree(alpha, beta, depth int) (score int) {
 					bestMove = move
 				} else {
 					p.cache(move, score, depth, ply, cacheBeta)
+					game.saveCutoff(moveCount)
+					if !inCheck {
+						game.saveGood(depth, move)
+					}
 					return score
 				}
 			}
@@ -206,7 +251,7 @@ func (p *Position) searchTree(alpha, beta, depth int) (score int) {
 	}
 
 	if moveCount == 0 {
-		score = let(inCheck, matedIn(ply), 0)
+		score = let(inCheck, matedIn(ply), drawIn(ply))
 	} else {
 		score = bestScore
 		if !inCheck {
@@ -224,3 +269,18 @@ func (p *Position) searchTree(alpha, beta, depth int) (score int) {
 
 	return score
 }
+
+// Pruning margins are set in centipawns for the standard pawn value and get
+// scaled by the actual pawn value so that pruning stays sound when piece
+// values change.
+func pawnUnits(centipawns int) int {
+	return centipawns * valuePawn.midgame / onePawn
+}
+
+func razoringMargin(depth int) int {
+	return pawnUnits(96 + 64 * (depth - 1))
+}
+
+func futilityMargin(depth int) int {
+	return pawnUnits(256 * depth)
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2014-2018 by Michael Dvorkin. All Rights Reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+//
+// I am making my contributions/submissions to this project solely in my
+// personal capacity and am not conveying any rights to any intellectual
+// property of any third parties.
+
+package donna
+
+// Tablebase win score is kept below checkmate range so that tablebase wins
+// don't get reported as mates.
+const TablebaseWin = Checkmate - 2 * MaxPly
+
+// Tablebase win/draw/loss values from the point of view of the side to move.
+const (
+	tbLoss = -1
+	tbDraw = 0
+	tbWin = 1
+)
+
+// Endgame tablebase probing interface. Probes return false if the position
+// is not covered by the tablebase or the table is not available.
+type Tablebase interface {
+	Cardinality() int 			// Max number of pieces including kings.
+	ProbeWDL(p *Position) (wdl int, ok bool) // Win, draw, or loss for the side to move.
+	ProbeDTZ(p *Position) (dtz int, ok bool) // Plies to the next capture or pawn move.
+}
+
+// Built-in KPK bitbase that NewEngine() sets up by default. Other tablebases, ex. Syzygy, could be plugged in by
+// implementing the Tablebase interface and passing it to NewEngine().
+type Bitbase struct{}
+
+func (Bitbase) Cardinality() int {
+	return 3
+}
+
+func (Bitbase) ProbeWDL(p *Position) (wdl int, ok bool) {
+	var stronger int
+	switch p.balance { // Material signature lookup: KPvK, KvKP, or KvK.
+	case 0:
+		return tbDraw, true
+	case materialBalance[Pawn]:
+		stronger = White
+	case materialBalance[BlackPawn]:
+		stronger = Black
+	default:
+		return tbDraw, false
+	}
+
+	if !p.kpkWinning(stronger) {
+		return tbDraw, true
+	}
+
+	return let(stronger == p.color, tbWin, tbLoss), true
+}
+
+func (Bitbase) ProbeDTZ(p *Position) (dtz int, ok bool) {
+	var color, wKing, bKing, wPawn int
+	switch p.balance {
+	case 0:
+		return 0, true
+	case materialBalance[Pawn]:
+		color, wKing, bKing, wPawn = p.color, p.king[White], p.king[Black], p.outposts[Pawn].last()
+	case materialBalance[BlackPawn]:
+		color, wKing, bKing, wPawn = p.color ^ 1, 64 + ^p.king[Black], 64 + ^p.king[White], 64 + ^p.outposts[BlackPawn].last()
+	default:
+		return 0, false
+	}
+
+	if kpkDistance[wPawn - 8] == nil {
+		kpkDistance[wPawn - 8] = kpkDistanceFor(wPawn)
+	}
+	dtz = int(kpkDistance[wPawn - 8][color][wKing][bKing])
+
+	return let(color == White, dtz, -dtz), true
+}
+
+// Distance to zeroing move in KPK gets computed lazily for each pawn square
+// by retrograde analysis on top of the bitbase, with the white pawn and white
+// side winning. Zero distance means the position is not won.
+var kpkDistance [48]*[2][64][64]int8
+
+func kpkDistanceFor(wPawn int) *[2][64][64]int8 {
+	dist := new([2][64][64]int8)
+
+	// The win gets converted right away by pushing or promoting the pawn.
+	zeroing := func(wKing, bKing int) bool {
+		push := wPawn + 8
+		if push == wKing || push == bKing {
+			return false
+		}
+		if push >= A8 {
+			return distance[bKing][push] > 1 || distance[wKing][push] == 1
+		}
+		if kpkLookup(Black, wKing, bKing, push) {
+			return true
+		}
+		return row(wPawn) == A2H2 && push + 8 != wKing && push + 8 != bKing && kpkLookup(Black, wKing, bKing, push + 8)
+	}
+
+	for wKing := A1; wKing <= H8; wKing++ {
+		for bKing := A1; bKing <= H8; bKing++ {
+			if kpkLookup(White, wKing, bKing, wPawn) && zeroing(wKing, bKing) {
+				dist[White][wKing][bKing] = 1
+			}
+		}
+	}
+
+	// White picks the fastest king move that keeps the win, and black picks
+	// the slowest one. Each pass settles the positions at the next distance.
+	for n := int8(2); ; n++ {
+		updates := 0
+		for wKing := A1; wKing <= H8; wKing++ {
+			for bKing := A1; bKing <= H8; bKing++ {
+				if dist[White][wKing][bKing] == 0 && kpkLookup(White, wKing, bKing, wPawn) {
+					for bm := kingMoves[wKing] & ^(kingMoves[bKing] | bit[wPawn]); bm.any(); bm = bm.pop() {
+						if dist[Black][bm.first()][bKing] == n - 1 {
+							dist[White][wKing][bKing] = n
+							updates++
+							break
+						}
+					}
+				}
+				if dist[Black][wKing][bKing] == 0 && kpkLookup(Black, wKing, bKing, wPawn) {
+					longest := 0
+					for bm := kingMoves[bKing] & ^(kingMoves[wKing] | pawnAttacks[White][wPawn]); bm.any(); bm = bm.pop() {
+						square := bm.first()
+						if square == wPawn || dist[White][wKing][square] == 0 || dist[White][wKing][square] >= n {
+							longest = 0
+							break
+						}
+						longest = max(longest, int(dist[White][wKing][square]))
+					}
+					if longest == int(n) - 1 {
+						dist[Black][wKing][bKing] = n
+						updates++
+					}
+				}
+			}
+		}
+		if updates == 0 {
+			return dist
+		}
+	}
+}
+
+// Probes the tablebase, if any, and returns the score of the position with
+// the wins and losses adjusted for the distance from the root.
+func (p *Position) probeTablebase() (score int, ok bool) {
+	tb := engine.tablebase
+	if tb == nil || p.board.count() > tb.Cardinality() {
+		return 0, false
+	}
+
+	wdl, ok := tb.ProbeWDL(p)
+	if !ok {
+		return 0, false
+	}
+
+	game.stats.TablebaseHits++
+	switch wdl {
+	case tbWin:
+		return TablebaseWin - ply(), true
+	case tbLoss:
+		return -TablebaseWin + ply(), true
+	}
+
+	return drawIn(ply()), true
+}
+
+// Picks the root move using tablebase probes: the win with the shortest
+// distance to zeroing move, the draw, or the loss with the longest distance
+// if that's all we've got. Returns Move(0) if any of the moves are missing
+// from the tablebase.
+func (game *Game) tablebaseMove(p *Position) Move {
+	tb := engine.tablebase
+	if tb == nil || p.board.count() > tb.Cardinality() {
+		return Move(0)
+	}
+
+	best, bestScore := Move(0), -Checkmate
+	gen := NewRootGen(p, 1).generateAllMoves().validOnly()
+	for move := gen.nextMove(); move.some(); move = gen.nextMove() {
+		position := p.makeMove(move)
+		wdl, found := tb.ProbeWDL(position)
+		dtz, exact := tb.ProbeDTZ(position)
+		position.undoLastMove()
+		if !found || !exact {
+			return Move(0)
+		}
+
+		// Probe results are from the opponent's point of view. Winning pawn
+		// moves and captures reset the distance themselves.
+		if wdl == tbLoss && (move.piece().isPawn() || move.isCapture()) {
+			dtz = 0
+		}
+		score := -wdl * MaxPly * 2
+		if wdl != tbDraw {
+			score += wdl * abs(dtz)
+		}
+		if score > bestScore {
+			best, bestScore = move, score
+		}
+	}
+
+	return best
+}
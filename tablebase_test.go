@@ -0,0 +1,105 @@
+// Copyright (c) 2014-2018 by Michael Dvorkin. All Rights Reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+//
+// I am making my contributions/submissions to this project solely in my
+// personal capacity and am not conveying any rights to any intellectual
+// property of any third parties.
+
+package donna
+
+import(`github.com/michaeldv/donna/expect`; `io`; `testing`)
+
+// Tablebase with win/draw/loss probes only, like Syzygy WDL files without
+// matching DTZ ones.
+type wdlTablebase struct{ Bitbase }
+
+func (wdlTablebase) ProbeDTZ(p *Position) (int, bool) {
+	return 0, false
+}
+
+func TestTablebase000(t *testing.T) {
+	p := NewGame(`Ke6,e5`, `Ke8`).start()
+	wdl, ok := Bitbase{}.ProbeWDL(p)
+	expect.True(t, ok)
+	expect.Eq(t, wdl, tbWin)
+
+	p = NewGame(`Ke6,e5`, `M,Ke8`).start()
+	wdl, _ = Bitbase{}.ProbeWDL(p)
+	expect.Eq(t, wdl, tbLoss)
+
+	p = NewGame(`Ke1`, `M,Ke3,e4`).start()
+	wdl, ok = Bitbase{}.ProbeWDL(p)
+	expect.True(t, ok)
+	expect.Eq(t, wdl, tbWin)
+
+	p = NewGame(`Ka1,a4`, `Ka8`).start()
+	wdl, ok = Bitbase{}.ProbeWDL(p)
+	expect.True(t, ok)
+	expect.Eq(t, wdl, tbDraw)
+
+	p = NewGame(`Ke1,Ra1`, `Ke8`).start()
+	_, ok = Bitbase{}.ProbeWDL(p)
+	expect.False(t, ok)
+}
+
+// Search with tablebase probes finds the winning and drawing moves in KPK
+// when the root move can't be picked by DTZ probes.
+func TestTablebase010(t *testing.T) {
+	engine.tablebase = wdlTablebase{}; defer func() { engine.tablebase = nil }()
+
+	NewGame(`Kd5,e4`, `Ke7`).start()
+	move, stats := think(4, 0, false)
+	expect.Eq(t, move, `Kd5-e5`)
+	expect.True(t, stats.TablebaseHits > 0)
+	expect.True(t, game.score > WhiteWinning && !isMate(game.score))
+
+	NewGame(`Kd4,e4`, `M,Kd6`).start()
+	move, _ = think(4, 0, false)
+	expect.Eq(t, move, `Kd6-e6`)
+	expect.Eq(t, game.score, DrawScore)
+}
+
+// Root move gets picked by DTZ probes without search.
+func TestTablebase020(t *testing.T) {
+	engine.tablebase = Bitbase{}; defer func() { engine.tablebase = nil }()
+	engine.output = io.Discard; defer func() { engine.output = nil }()
+
+	NewGame(`Kd5,e4`, `Ke7`).start()
+	move, stats := game.ThinkWithStats()
+	expect.Eq(t, move, `Kd5-e5`)
+	expect.Eq(t, stats.Nodes, 0)
+
+	p := NewGame(`Kd6,e5`, `Kd8`).start() // Pawn push is the fastest win.
+	move = game.Think()
+	expect.Eq(t, move, `e5-e6`)
+	wdl, _ := Bitbase{}.ProbeWDL(p.makeMove(move))
+	expect.Eq(t, wdl, tbLoss)
+}
+
+// Distance to zeroing move goes down by one ply with each best move.
+func TestTablebase030(t *testing.T) {
+	p := NewGame(`Kd6,e5`, `Kd8`).start()
+	dtz, ok := Bitbase{}.ProbeDTZ(p)
+	expect.True(t, ok)
+	expect.Eq(t, dtz, 1)
+
+	p = NewGame(`Kd1`, `M,Kd3,e4`).start()
+	dtz, _ = Bitbase{}.ProbeDTZ(p)
+	expect.Eq(t, dtz, 1)
+
+	p = NewGame(`Kd5,e4`, `Ke7`).start()
+	dtz, _ = Bitbase{}.ProbeDTZ(p)
+	expect.Eq(t, dtz, 5)
+	after, _ := Bitbase{}.ProbeDTZ(p.makeMove(NewMove(p, D5, E5)))
+	expect.Eq(t, after, -4)
+
+	p = NewGame(`Ka1,a4`, `Ka8`).start()
+	dtz, ok = Bitbase{}.ProbeDTZ(p)
+	expect.True(t, ok)
+	expect.Eq(t, dtz, 0)
+
+	p = NewGame(`Ke1,Ra1`, `Ke8`).start()
+	_, ok = Bitbase{}.ProbeDTZ(p)
+	expect.False(t, ok)
+}
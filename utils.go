@@ -10,9 +10,21 @@ package donna
 
 import (
 	`fmt`
+	`math/rand`
 	`time`
 )
 
+// Single source of pseudo-random numbers used throughout the engine. It
+// gets seeded with fixed value so that the results are reproducible.
+var random = rand.New(rand.NewSource(defaultSeed))
+
+const defaultSeed = 0x5EED
+
+// Reseeds the engine's random number generator.
+func SetSeed(seed uint64) {
+	random.Seed(int64(seed))
+}
+
 // Returns row number in 0..7 range for the given square.
 func row(square int) int {
 	return square >> 3
@@ -48,10 +60,20 @@ func let(ok bool, yes, no int) int {
 	return no
 }
 
+// Flips the square vertically (ex. E2 becomes E7).
+func Flip(square int) int {
+	return square ^ A8
+}
+
+// Returns the opposite color.
+func FlipColor(color int) int {
+	return color ^ 1
+}
+
 // Flips the square verically for white (ex. E2 becomes E7).
 func flip(color int, square int) int {
 	if color == White {
-		return square ^ 56
+		return Flip(square)
 	}
 	return square
 }
@@ -87,6 +109,16 @@ func mateDistance(alpha, beta, ply int) (int, int) {
 	return max(matedIn(ply), alpha), min(matingIn(ply + 1), beta)
 }
 
+// Returns a score of the draw in given number of plies. With positive contempt
+// the draw is valued slightly negative for the side that started the search
+// and slightly positive for its opponent.
+func drawIn(ply int) int {
+	if ply & 1 == 0 {
+		return DrawScore - engine.contempt
+	}
+	return DrawScore + engine.contempt
+}
+
 func isMate(score int) bool {
 	return abs(score) >= Checkmate - MaxPly
 }
@@ -141,20 +173,21 @@ func nps(duration int64) int64 {
 	return nodes
 }
 
-// Returns a number of used items in a sample of 1000 cache entries.
+// Returns permille of cache entries used by current search. To keep it cheap
+// we only sample the first 1000 entries.
 func hashfull() int {
-	count := 0
+	count, sample := 0, min(1000, len(game.cache))
 
-	if cacheSize := len(game.cache); cacheSize > 1000 {
-		start := (game.nodes + game.qnodes) % (cacheSize - 1000) // 0 <= start < cacheSize - 1000.
-		for i := start; i < start + 1000; i++ {
-			if game.cache[i].token() == game.token {
-				count++
-			}
+	for i := 0; i < sample; i++ {
+		if game.cache[i].token() == game.token {
+			count++
 		}
 	}
+	if sample > 0 {
+		return count * 1000 / sample
+	}
 
-	return count
+	return 0
 }
 
 // Formats time duration in milliseconds in human readable form (MM:SS.XXX).
@@ -186,7 +219,7 @@ func Summary(metrics map[string]interface{}) {
 	fmt.Printf("%-12s    -      -    %5.2f  |    -      -    %5.2f  >  %5.2f\n", `Imbalance`,
 		float32(material.midgame)/units, float32(material.endgame)/units, float32(material.blended(phase))/units)
 
-	for _, tag := range([]string{`Tempo`, `Center`, `Threats`, `Pawns`, `Passers`, `Mobility`, `+Pieces`, `-Knights`, `-Bishops`, `-Rooks`, `-Queens`, `+King`, `-Cover`, `-Safety`}) {
+	for _, tag := range([]string{`Tempo`, `Center`, `Threats`, `Pawns`, `Passers`, `-Tarrasch`, `Mobility`, `+Pieces`, `-Knights`, `-Bishops`, `-Rooks`, `-Queens`, `+King`, `-Cover`, `-Storm`, `-Safety`}) {
 		white := metrics[tag].(Total).white
 		black := metrics[tag].(Total).black
 
@@ -0,0 +1,41 @@
+// Copyright (c) 2014-2018 by Michael Dvorkin. All Rights Reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+//
+// I am making my contributions/submissions to this project solely in my
+// personal capacity and am not conveying any rights to any intellectual
+// property of any third parties.
+
+package donna
+
+import(`github.com/michaeldv/donna/expect`; `testing`)
+
+// Flipping squares and colors.
+func TestUtils000(t *testing.T) {
+	expect.Eq(t, Flip(E2), E7)
+	expect.Eq(t, Flip(A8), A1)
+	expect.Eq(t, FlipColor(White), Black)
+	expect.Eq(t, FlipColor(Black), White)
+
+	for sq := A1; sq <= H8; sq++ {
+		expect.Eq(t, Flip(Flip(sq)), sq)
+		expect.Eq(t, col(Flip(sq)), col(sq))
+		expect.Eq(t, rank(White, sq), rank(Black, Flip(sq)))
+		expect.Eq(t, flip(White, sq), Flip(flip(Black, sq)))
+	}
+}
+
+// Rank relative scoring is symmetric for both sides.
+func TestUtils010(t *testing.T) {
+	for sq := A1; sq <= H8; sq++ {
+		for piece := Pawn; piece <= King; piece += 2 {
+			white, black := pst[piece][sq], pst[piece|Black][Flip(sq)]
+			expect.Eq(t, white.midgame, -black.midgame)
+			expect.Eq(t, white.endgame, -black.endgame)
+		}
+	}
+
+	white := NewGame(`Kg1,Nf3,Bc4,e4,f2,g2,h2`, `Kg8,Nc6,d6,f7,g7,h7`).start().Evaluate()
+	black := NewGame(`Kg1,Nc3,d3,f2,g2,h2`, `M,Kg8,Nf6,Bc5,e5,f7,g7,h7`).start().Evaluate()
+	expect.Eq(t, white, black)
+}